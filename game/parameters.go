@@ -2,14 +2,19 @@ package game
 
 import (
 	"encoding/json"
+	"fmt"
 	"log"
 	"os"
+
+	"github.com/eaglesight/eaglesight-server/world"
 )
 
 // Parameters contains all the parameters of the game
 type Parameters struct {
-	GameID  string          `json:"gameId"`
-	Players []PlayerProfile `json:"profiles"`
+	GameID   string          `json:"gameId"`
+	Players  []PlayerProfile `json:"profiles"`
+	Scenario world.Scenario  `json:"scenario"`
+	Rules    world.Rules     `json:"rules"`
 }
 
 // LoadGameParametersFromFile load the parameters of a game from a local JSON file (TEST THIS?)
@@ -26,5 +31,24 @@ func LoadGameParametersFromFile(filepath string) Parameters {
 	if err := json.NewDecoder(reader).Decode(&data); err != nil {
 		log.Fatal(err)
 	}
+
+	if err := data.validate(); err != nil {
+		log.Fatal(err)
+	}
 	return data
 }
+
+// validate checks that the parameters can be used by the world
+func (p *Parameters) validate() error {
+
+	for _, profile := range p.Players {
+		if world.IsReservedUID(profile.UID) {
+			return fmt.Errorf("The uid %d of %s is reserved", profile.UID, profile.Name)
+		}
+	}
+
+	if len(p.Scenario.Targets) > world.MaxTargets {
+		return fmt.Errorf("The scenario has %d targets, the maximum is %d", len(p.Scenario.Targets), world.MaxTargets)
+	}
+	return nil
+}
@@ -0,0 +1,34 @@
+package game
+
+import (
+	"testing"
+
+	"github.com/eaglesight/eaglesight-server/world"
+)
+
+func TestValidateParameters(t *testing.T) {
+	params := dummyParams()
+
+	if err := params.validate(); err != nil {
+		t.Errorf("The parameters should be valid: %s", err)
+	}
+
+	params.Players = append(params.Players, PlayerProfile{Name: "turret", UID: world.NPCSource})
+
+	if err := params.validate(); err == nil {
+		t.Error("The uid of the NPCs can't be used by a player")
+	}
+
+	params.Players[len(params.Players)-1].UID = world.NoWinner
+
+	if err := params.validate(); err == nil {
+		t.Error("The uid of a draw can't be used by a player")
+	}
+
+	params = dummyParams()
+	params.Scenario.Targets = make([]world.GroundTargetModel, world.MaxTargets+1)
+
+	if err := params.validate(); err == nil {
+		t.Error("The scenario can't have more targets than the maximum")
+	}
+}
@@ -17,6 +17,7 @@ type PlayerProfile struct {
 	Name  string           `json:"username"`
 	UUID  string           `json:"accessKey"`
 	UID   uint8            `json:"uid"`
+	Team  uint8            `json:"team"` // 0 means no team
 	Model world.PlaneModel `json:"planeModel"`
 }
 
@@ -0,0 +1,67 @@
+package game
+
+import (
+	"encoding/binary"
+	"log"
+	"sort"
+
+	"github.com/eaglesight/eaglesight-server/world"
+)
+
+// scoreSize : uint8 (uid) + uint8 (team) + uint16 * 5 (kills, deaths, assists, team kills, targets) + uint32 (damage)
+const scoreSize = 1 + 1 + 2*5 + 4
+
+// scoreboardMessage sends the scores of the players, sorted by uid:
+// 0xF|final (1 at the end of the match)|count|(uid|team|kills|deaths|assists|team kills|targets (uint16)|damage (uint32))...
+func scoreboardMessage(scores map[uint8]world.Score, final bool) []byte {
+
+	uids := make([]int, 0, len(scores))
+	for uid := range scores {
+		uids = append(uids, int(uid))
+	}
+	sort.Ints(uids)
+
+	message := make([]byte, 3+scoreSize*len(scores))
+	message[0] = 0xF
+	if final {
+		message[1] = 1
+	}
+	message[2] = uint8(len(scores))
+
+	offset := 3
+	for _, uid := range uids {
+		score := scores[uint8(uid)]
+		message[offset] = uint8(uid)
+		message[offset+1] = score.Team
+		binary.BigEndian.PutUint16(message[offset+2:], score.Kills)
+		binary.BigEndian.PutUint16(message[offset+4:], score.Deaths)
+		binary.BigEndian.PutUint16(message[offset+6:], score.Assists)
+		binary.BigEndian.PutUint16(message[offset+8:], score.TeamKills)
+		binary.BigEndian.PutUint16(message[offset+10:], score.Targets)
+		binary.BigEndian.PutUint32(message[offset+12:], score.Damage)
+		offset += scoreSize
+	}
+	return message
+}
+
+// report logs the end-of-match report and sends the final scoreboard to the players
+func (s *Server) report(results world.MatchResults) {
+
+	scores := results.Scores
+
+	names := make(map[uint8]string)
+	for _, profile := range s.profiles {
+		names[profile.UID] = profile.Name
+	}
+
+	log.Printf("Match %s is over. Winner: %d\n", s.gameID, results.Winner)
+	for uid, score := range scores {
+		log.Printf("%s (team %d): %d kills, %d deaths, %d assists, %d team kills, %d targets, %d damage\n",
+			names[uid], score.Team, score.Kills, score.Deaths, score.Assists, score.TeamKills, score.Targets, score.Damage)
+	}
+	for team, points := range results.TeamScores {
+		log.Printf("Team %d: %d points\n", team, points)
+	}
+
+	s.broadcastMessage(scoreboardMessage(scores, true))
+}
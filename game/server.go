@@ -16,8 +16,13 @@ type Server struct {
 	deconnect        chan *Player
 	connectedPlayers map[uint8]*Player
 	profiles         map[string]PlayerProfile
+	stop             chan bool
+	restart          bool // A new match starts when one is over
 }
 
+// scoreboardInterval is the time between two scoreboards sent to the players
+const scoreboardInterval = 5 * time.Second
+
 // NewServer return a arena with default settings (TEST THIS!)
 func NewServer(params Parameters) *Server {
 	// Put all the registered players in a map
@@ -35,6 +40,8 @@ func NewServer(params Parameters) *Server {
 		deconnect:        make(chan *Player, 1),
 		connectedPlayers: make(map[uint8]*Player),
 		profiles:         profiles,
+		stop:             make(chan bool, 1),
+		restart:          params.Rules.Match.Restart,
 	}
 }
 
@@ -92,25 +99,81 @@ func (s *Server) Run(world *world.World, connectors ...Connector) {
 		go connector.Start(s)
 	}
 
+	scoreboardTicker := time.NewTicker(scoreboardInterval)
+	defer scoreboardTicker.Stop()
+
 	log.Println("Here we go!")
 	for {
 		select {
 		case snapshot := <-world.Snapshots:
 			s.broadcastMessage(snapshot)
+		case event := <-world.Events:
+			s.sendEvent(event)
 		case request := <-s.verification:
 			s.verify(&request)
 		case player := <-s.connect:
 			go player.Listen(world.Input, s.deconnect)
-			world.Join(player.profile.UID, player.profile.Model)
+			profile := player.profile
+			s.whileWaiting(world, func() { world.Join(profile.UID, profile.Team, profile.Model) })
 			s.connectPlayer(player)
 		case player := <-s.deconnect:
-			world.Leave(player.profile.UID)
+			uid := player.profile.UID
+			s.whileWaiting(world, func() { world.Leave(uid) })
 			s.deconnectPlayer(player)
+		case <-scoreboardTicker.C:
+			s.broadcastMessage(scoreboardMessage(world.Scoreboard().Scores(), false))
+		case results := <-world.Over:
+			s.report(results)
+			if !s.restart {
+				s.endWorld(world)
+				return
+			}
+		case <-s.stop:
+			s.endWorld(world)
+			s.report(world.Results())
+			return
 		}
 	}
 
 }
 
+// Stop ends the match before its end. Run sends the report and returns.
+// It doesn't wait: Run may have returned already
+func (s *Server) Stop() {
+
+	select {
+	case s.stop <- true:
+	default:
+	}
+}
+
+// endWorld stops the world
+func (s *Server) endWorld(w *world.World) {
+	s.whileWaiting(w, func() { w.End <- true })
+}
+
+// whileWaiting calls "call", which waits for the world. The world may be busy sending a snapshot or events,
+// so they are still handled meanwhile. Otherwise, both would wait for each other
+func (s *Server) whileWaiting(w *world.World, call func()) {
+
+	done := make(chan bool)
+	go func() {
+		call()
+		close(done)
+	}()
+
+	for {
+		select {
+		case <-done:
+			return
+		case snapshot := <-w.Snapshots:
+			s.broadcastMessage(snapshot)
+		case event := <-w.Events:
+			s.sendEvent(event)
+		}
+	}
+}
+
 // broadcast broadcasts a message to all players
 func (s *Server) broadcastMessage(message []byte) {
 	for _, p := range s.connectedPlayers {
@@ -118,18 +181,33 @@ func (s *Server) broadcastMessage(message []byte) {
 	}
 }
 
-// sendPlayersList Sends the list of all the connected players
-// including "player" itself in first position
-func (s *Server) playersListMessage(uid uint8) []byte {
+// sendEvent sends an event of the world to the players concerned
+func (s *Server) sendEvent(event world.Event) {
 
-	offset := 1 + 1
-	message := make([]byte, offset+len(s.connectedPlayers))
+	if !event.Private {
+		s.broadcastMessage(event.Data)
+		return
+	}
+
+	if player, ok := s.connectedPlayers[event.Recipient]; ok {
+		player.Write(event.Data)
+	}
+}
+
+// sendPlayersList Sends the list of all the connected players with their team
+// including "player" itself in first position: 0x4|uid|team|(uid|team)...
+func (s *Server) playersListMessage(uid uint8, team uint8) []byte {
+
+	offset := 1 + 2
+	message := make([]byte, offset+2*len(s.connectedPlayers))
 	message[0] = 0x4 // 4 == List of players
 	message[1] = uid
+	message[2] = team
 
-	for k := range s.connectedPlayers {
+	for k, player := range s.connectedPlayers {
 		message[offset] = k
-		offset++
+		message[offset+1] = player.profile.Team
+		offset += 2
 	}
 	return message
 }
@@ -139,19 +217,20 @@ func (s *Server) connectPlayer(player *Player) {
 	log.Printf("Connecting player with UUID %s", player.profile.UUID)
 
 	// Send the players list to a player
-	player.Write(s.playersListMessage(player.profile.UID))
+	player.Write(s.playersListMessage(player.profile.UID, player.profile.Team))
 
 	s.connectedPlayers[player.profile.UID] = player
 
-	s.broadcastMessage(connectionMessage(player.profile.UID))
+	s.broadcastMessage(connectionMessage(player.profile.UID, player.profile.Team))
 	log.Println(player.profile.Name + " connected.")
 }
 
-func connectionMessage(UID uint8) []byte {
-	// 0x1 + player's uid
-	message := make([]byte, 2)
+func connectionMessage(UID uint8, team uint8) []byte {
+	// 0x1 + player's uid + player's team
+	message := make([]byte, 3)
 	message[0] = 0x1 // Connection
 	message[1] = UID
+	message[2] = team
 	return message
 }
 
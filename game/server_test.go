@@ -1,6 +1,7 @@
 package game
 
 import (
+	"reflect"
 	"testing"
 
 	"github.com/eaglesight/eaglesight-server/world"
@@ -13,6 +14,7 @@ func dummyParams() (params Parameters) {
 		Name:  "pako_panda",
 		UUID:  "pako",
 		UID:   0,
+		Team:  1,
 		Model: world.PlaneModel{},
 	})
 
@@ -40,7 +42,7 @@ func TestNewServer(t *testing.T) {
 		t.Fail()
 	}
 
-	if server.profiles[p1.UUID] != p1 {
+	if !reflect.DeepEqual(server.profiles[p1.UUID], p1) {
 		t.Fail()
 	}
 
@@ -89,7 +91,7 @@ func TestConnect(t *testing.T) {
 
 	p := <-server.connect
 
-	if p.profile != server.profiles["pako"] {
+	if !reflect.DeepEqual(p.profile, server.profiles["pako"]) {
 		t.Fail()
 	}
 
@@ -115,6 +117,30 @@ func TestBroadcastMessage(t *testing.T) {
 	}
 }
 
+func TestSendEvent(t *testing.T) {
+
+	server := dummyServer()
+	profile := server.profiles["pako"]
+
+	conn := dummyConn()
+	server.connectedPlayers[profile.UID] = NewPlayer(profile, conn)
+
+	otherConn := dummyConn()
+	server.connectedPlayers[7] = NewPlayer(PlayerProfile{UID: 7}, otherConn)
+
+	server.sendEvent(world.Event{Data: []byte{0x7, 1}, Private: true, Recipient: profile.UID})
+
+	if len(conn.conn) != 1 || len(otherConn.conn) != 0 {
+		t.Error("Only the recipient should receive a private event")
+	}
+
+	server.sendEvent(world.Event{Data: []byte{0x5, 1, 1}})
+
+	if len(conn.conn) != 2 || len(otherConn.conn) != 1 {
+		t.Error("Everybody should receive a public event")
+	}
+}
+
 func TestPlayersListMessage(t *testing.T) {
 
 	server := dummyServer()
@@ -122,18 +148,18 @@ func TestPlayersListMessage(t *testing.T) {
 	profile := server.profiles["pako"]
 	server.connectedPlayers[profile.UID] = NewPlayer(profile, conn)
 
-	list := server.playersListMessage(1)
+	list := server.playersListMessage(1, 2)
 
-	if list[1] != 1 || list[2] != profile.UID || len(list) != 3 {
+	if list[1] != 1 || list[2] != 2 || list[3] != profile.UID || list[4] != profile.Team || len(list) != 5 {
 		t.Fail()
 	}
 }
 
 func TestConnectionMessage(t *testing.T) {
 
-	message := connectionMessage(2)
+	message := connectionMessage(2, 1)
 
-	if message[0] != 0x1 || message[1] != 2 {
+	if message[0] != 0x1 || message[1] != 2 || message[2] != 1 {
 		t.Fail()
 	}
 }
@@ -180,3 +206,46 @@ func TestDeconnectPlayer(t *testing.T) {
 	}
 
 }
+
+func TestWhileWaiting(t *testing.T) {
+
+	server := dummyServer()
+	w := &world.World{Events: make(chan world.Event, 16), Snapshots: make(chan []byte, 1)}
+
+	// More events than the world can queue: it waits for the server
+	server.whileWaiting(w, func() {
+		for i := 0; i < cap(w.Events)+2; i++ {
+			w.Events <- world.Event{Data: []byte{0x5}}
+		}
+		w.Snapshots <- []byte{0x3}
+		w.Snapshots <- []byte{0x3}
+	})
+	// Getting here means the server handled what the world sent while it waited
+}
+
+func TestScoreboardMessage(t *testing.T) {
+
+	scores := map[uint8]world.Score{
+		3: {Team: 2, Kills: 1},
+		1: {Team: 1, Deaths: 2, Damage: 300},
+	}
+	message := scoreboardMessage(scores, true)
+
+	if message[0] != 0xF || message[1] != 1 || message[2] != 2 || len(message) != 3+2*scoreSize {
+		t.Fatalf("Wrong header: %v", message)
+	}
+
+	// Sorted by uid
+	if message[3] != 1 || message[4] != 1 || message[3+5] != 2 || message[3+15] != 44 || message[3+scoreSize] != 3 || message[3+scoreSize+3] != 1 {
+		t.Errorf("Wrong scores: %v", message)
+	}
+}
+
+func TestStopAfterRun(t *testing.T) {
+
+	server := dummyServer()
+
+	// Nobody runs the server anymore
+	server.Stop()
+	server.Stop()
+}
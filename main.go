@@ -2,6 +2,8 @@ package main
 
 import (
 	"flag"
+	"os"
+	"os/signal"
 
 	"github.com/eaglesight/eaglesight-server/game"
 	"github.com/eaglesight/eaglesight-server/world"
@@ -18,9 +20,18 @@ func main() {
 
 	params := game.LoadGameParametersFromFile(*paramsFileLocation)
 	terrain, _ := world.LoadTerrain(*terrainLocation)
-	world := world.NewWorld(terrain)
+	world := world.NewWorld(terrain, params.Scenario, params.Rules)
 
 	server := game.NewServer(params)
 	wsconn := wsconnector.NewConnector(uint16(*wsport))
+
+	// Ctrl+C ends the match properly, with its report
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt)
+	go func() {
+		<-interrupt
+		server.Stop()
+	}()
+
 	server.Run(world, wsconn)
 }
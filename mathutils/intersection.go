@@ -0,0 +1,113 @@
+package mathutils
+
+import "math"
+
+// SegmentSphereIntersection returns where the segment [a, b] enters the sphere (center, radius).
+// t is the position on the segment: 0 is a, 1 is b. If a is already in the sphere, t is 0.
+func SegmentSphereIntersection(a Vector3D, b Vector3D, center Vector3D, radius float64) (t float64, hit bool) {
+
+	d := b.Sub(a)
+	m := a.Sub(center)
+
+	c := DotProduct(&m, &m) - radius*radius
+
+	// a is inside the sphere
+	if c <= 0 {
+		return 0, true
+	}
+
+	dd := DotProduct(&d, &d)
+	if dd == 0 {
+		return 0, false
+	}
+
+	md := DotProduct(&m, &d)
+	// Going away from the sphere
+	if md > 0 {
+		return 0, false
+	}
+
+	discriminant := md*md - dd*c
+	if discriminant < 0 {
+		return 0, false
+	}
+
+	t = (-md - math.Sqrt(discriminant)) / dd
+
+	if t > 1 {
+		return 0, false
+	}
+	return t, true
+}
+
+// SegmentTriangleIntersection returns where the segment [a, b] crosses the triangle (Möller–Trumbore).
+// t is the position on the segment: 0 is a, 1 is b.
+func SegmentTriangleIntersection(a Vector3D, b Vector3D, triangle *[3]Vector3D) (t float64, hit bool) {
+
+	const epsilon = 1e-9
+
+	d := b.Sub(a)
+	edge1 := triangle[1].Sub(triangle[0])
+	edge2 := triangle[2].Sub(triangle[0])
+
+	p := CrossProduct(&d, &edge2)
+	det := DotProduct(&edge1, &p)
+
+	// The segment is parallel to the triangle
+	if math.Abs(det) < epsilon {
+		return 0, false
+	}
+	invDet := 1 / det
+
+	s := a.Sub(triangle[0])
+	u := DotProduct(&s, &p) * invDet
+	if u < 0 || u > 1 {
+		return 0, false
+	}
+
+	q := CrossProduct(&s, &edge1)
+	v := DotProduct(&d, &q) * invDet
+	if v < 0 || u+v > 1 {
+		return 0, false
+	}
+
+	t = DotProduct(&edge2, &q) * invDet
+	if t < 0 || t > 1 {
+		return 0, false
+	}
+	return t, true
+}
+
+// Lerp returns the point at t on the segment [a, b]
+func Lerp(a Vector3D, b Vector3D, t float64) Vector3D {
+	d := b.Sub(a)
+	return a.Add(d.MulScalar(t))
+}
+
+// SegmentDiscIntersection returns where the segment [a, b] crosses the disc (center, normal, radius), from any side.
+// t is the position on the segment: 0 is a, 1 is b.
+func SegmentDiscIntersection(a Vector3D, b Vector3D, center Vector3D, normal Vector3D, radius float64) (t float64, hit bool) {
+
+	const epsilon = 1e-9
+
+	d := b.Sub(a)
+	denominator := DotProduct(&normal, &d)
+
+	// The segment is parallel to the disc
+	if math.Abs(denominator) < epsilon {
+		return 0, false
+	}
+
+	m := center.Sub(a)
+	t = DotProduct(&normal, &m) / denominator
+	if t < 0 || t > 1 {
+		return 0, false
+	}
+
+	// Where the segment crosses the plane of the disc
+	crossing := a.Add(d.MulScalar(t))
+	if Distance(crossing, center) > radius {
+		return 0, false
+	}
+	return t, true
+}
@@ -0,0 +1,92 @@
+package mathutils
+
+import (
+	"math"
+	"testing"
+)
+
+func TestSegmentSphereIntersection(t *testing.T) {
+	center := Vector3D{X: 10, Y: 0, Z: 0}
+
+	// Through the sphere
+	tt, hit := SegmentSphereIntersection(Vector3D{X: 0, Y: 0, Z: 0}, Vector3D{X: 20, Y: 0, Z: 0}, center, 2)
+	if !hit || math.Abs(tt-0.4) > 1e-9 {
+		t.Errorf("The segment should enter the sphere at 0.4, not %f (%t)", tt, hit)
+	}
+
+	// Too short
+	if _, hit = SegmentSphereIntersection(Vector3D{X: 0, Y: 0, Z: 0}, Vector3D{X: 5, Y: 0, Z: 0}, center, 2); hit {
+		t.Error("The segment stops before the sphere")
+	}
+
+	// Beside
+	if _, hit = SegmentSphereIntersection(Vector3D{X: 0, Y: 3, Z: 0}, Vector3D{X: 20, Y: 3, Z: 0}, center, 2); hit {
+		t.Error("The segment passes beside the sphere")
+	}
+
+	// Starts inside
+	if tt, hit = SegmentSphereIntersection(Vector3D{X: 10, Y: 1, Z: 0}, Vector3D{X: 20, Y: 1, Z: 0}, center, 2); !hit || tt != 0 {
+		t.Error("The segment starts inside the sphere")
+	}
+}
+
+func TestSegmentTriangleIntersection(t *testing.T) {
+	triangle := [3]Vector3D{
+		{X: 0, Y: 0, Z: 0},
+		{X: 10, Y: 0, Z: 0},
+		{X: 0, Y: 0, Z: 10},
+	}
+
+	tt, hit := SegmentTriangleIntersection(Vector3D{X: 2, Y: 5, Z: 2}, Vector3D{X: 2, Y: -5, Z: 2}, &triangle)
+	if !hit || math.Abs(tt-0.5) > 1e-9 {
+		t.Errorf("The segment should cross the triangle at 0.5, not %f (%t)", tt, hit)
+	}
+
+	// Outside of the triangle
+	if _, hit = SegmentTriangleIntersection(Vector3D{X: 8, Y: 5, Z: 8}, Vector3D{X: 8, Y: -5, Z: 8}, &triangle); hit {
+		t.Error("The segment passes beside the triangle")
+	}
+
+	// Stops above
+	if _, hit = SegmentTriangleIntersection(Vector3D{X: 2, Y: 5, Z: 2}, Vector3D{X: 2, Y: 1, Z: 2}, &triangle); hit {
+		t.Error("The segment stops above the triangle")
+	}
+}
+
+func TestLerp(t *testing.T) {
+	p := Lerp(Vector3D{X: 0, Y: 0, Z: 0}, Vector3D{X: 10, Y: 20, Z: 30}, 0.5)
+
+	if p != (Vector3D{X: 5, Y: 10, Z: 15}) {
+		t.Errorf("p should be (5, 10, 15), not %+v", p)
+	}
+}
+
+func TestSegmentDiscIntersection(t *testing.T) {
+	center := Vector3D{X: 0, Y: 100, Z: 50}
+	normal := Vector3D{X: 0, Y: 0, Z: 1}
+
+	// Through the disc, both ways
+	tt, hit := SegmentDiscIntersection(Vector3D{X: 5, Y: 100, Z: 0}, Vector3D{X: 5, Y: 100, Z: 100}, center, normal, 10)
+	if !hit || math.Abs(tt-0.5) > 1e-9 {
+		t.Errorf("The segment should cross the disc at 0.5, not %f (%t)", tt, hit)
+	}
+
+	if _, hit = SegmentDiscIntersection(Vector3D{X: 5, Y: 100, Z: 100}, Vector3D{X: 5, Y: 100, Z: 0}, center, normal, 10); !hit {
+		t.Error("The segment crosses the disc backward")
+	}
+
+	// Outside of the ring
+	if _, hit = SegmentDiscIntersection(Vector3D{X: 11, Y: 100, Z: 0}, Vector3D{X: 11, Y: 100, Z: 100}, center, normal, 10); hit {
+		t.Error("The segment passes beside the disc")
+	}
+
+	// Too short
+	if _, hit = SegmentDiscIntersection(Vector3D{X: 0, Y: 100, Z: 0}, Vector3D{X: 0, Y: 100, Z: 40}, center, normal, 10); hit {
+		t.Error("The segment stops before the disc")
+	}
+
+	// Parallel
+	if _, hit = SegmentDiscIntersection(Vector3D{X: -20, Y: 100, Z: 50}, Vector3D{X: 20, Y: 100, Z: 50}, center, normal, 10); hit {
+		t.Error("The segment is in the plane of the disc")
+	}
+}
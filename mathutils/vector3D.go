@@ -1,5 +1,7 @@
 package mathutils
 
+import "math"
+
 // Vector3D ...
 type Vector3D struct {
 	X float64 `json:"x"`
@@ -47,6 +49,22 @@ func (v *Vector3D) DivScalar(x float64) (c Vector3D) {
 	return c
 }
 
+// Length returns the length (magnitude) of the vector
+func (v *Vector3D) Length() float64 {
+	return math.Sqrt(v.X*v.X + v.Y*v.Y + v.Z*v.Z)
+}
+
+// DotProduct returns the dot product of u and v
+func DotProduct(u *Vector3D, v *Vector3D) float64 {
+	return u.X*v.X + u.Y*v.Y + u.Z*v.Z
+}
+
+// Distance returns the distance between the points a and b
+func Distance(a Vector3D, b Vector3D) float64 {
+	d := a.Sub(b)
+	return d.Length()
+}
+
 // CrossProduct returns the cross product of u and v
 func CrossProduct(u *Vector3D, v *Vector3D) (d Vector3D) {
 	d.X = u.Y*v.Z - u.Z*v.Y
@@ -75,3 +93,17 @@ func HighestInTriangle(triangle *[3]Vector3D) (h float64) {
 	}
 	return h
 }
+
+// ClosestPointOnSegment returns the point of the segment [a, b] that is the closest to p
+func ClosestPointOnSegment(p Vector3D, a Vector3D, b Vector3D) Vector3D {
+	ab := b.Sub(a)
+	lengthSquared := DotProduct(&ab, &ab)
+
+	if lengthSquared == 0 {
+		return a
+	}
+
+	ap := p.Sub(a)
+	t := math.Max(0, math.Min(1, DotProduct(&ap, &ab)/lengthSquared))
+	return a.Add(ab.MulScalar(t))
+}
@@ -163,3 +163,49 @@ func TestHighestInTriangle(t *testing.T) {
 		t.Fail()
 	}
 }
+
+func TestLength(t *testing.T) {
+	v := Vector3D{X: 2.0, Y: 3.0, Z: 6.0}
+
+	if v.Length() != 7.0 {
+		t.Errorf("Length should be 7.0, not %f", v.Length())
+	}
+}
+
+func TestDotProduct(t *testing.T) {
+	u := Vector3D{X: 1.0, Y: 2.0, Z: 3.0}
+	v := Vector3D{X: 4.0, Y: -5.0, Z: 6.0}
+
+	if DotProduct(&u, &v) != 12.0 {
+		t.Errorf("DotProduct should be 12.0, not %f", DotProduct(&u, &v))
+	}
+}
+
+func TestDistance(t *testing.T) {
+	a := Vector3D{X: 1.0, Y: 1.0, Z: 1.0}
+	b := Vector3D{X: 3.0, Y: 4.0, Z: 7.0}
+
+	if Distance(a, b) != 7.0 {
+		t.Errorf("Distance should be 7.0, not %f", Distance(a, b))
+	}
+}
+
+func TestClosestPointOnSegment(t *testing.T) {
+	a := Vector3D{X: 0, Y: 0, Z: 0}
+	b := Vector3D{X: 10, Y: 0, Z: 0}
+
+	// In the middle
+	if c := ClosestPointOnSegment(Vector3D{X: 4, Y: 3, Z: 0}, a, b); c != (Vector3D{X: 4, Y: 0, Z: 0}) {
+		t.Errorf("The closest point should be (4, 0, 0), not %+v", c)
+	}
+
+	// Before a
+	if c := ClosestPointOnSegment(Vector3D{X: -4, Y: 3, Z: 0}, a, b); c != a {
+		t.Errorf("The closest point should be a, not %+v", c)
+	}
+
+	// Degenerated segment
+	if c := ClosestPointOnSegment(Vector3D{X: 4, Y: 3, Z: 0}, a, a); c != a {
+		t.Errorf("The closest point should be a, not %+v", c)
+	}
+}
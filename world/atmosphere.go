@@ -0,0 +1,86 @@
+package world
+
+import "math"
+
+// Constants of the International Standard Atmosphere (ISA)
+const (
+	isaSeaLevelTemperature = 288.15   // Kelvin
+	isaSeaLevelPressure    = 101325   // Pascals
+	isaLapseRate           = 0.0065   // Kelvin / meter
+	isaTropopauseAltitude  = 11000    // meters
+	gasConstant            = 287.0529 // J / (kg * K), specific gas constant of dry air
+	standardGravity        = 9.80665  // m / s²
+	heatCapacityRatio      = 1.4      // Of the dry air
+)
+
+// SeaLevelDensity is the air density at sea level in the standard atmosphere
+var SeaLevelDensity = isaSeaLevelPressure / (gasConstant * isaSeaLevelTemperature)
+
+// Atmosphere describes the air of a map. It follows the ISA model: the temperature
+// decreases linearly in the troposphere and is constant in the (lower) stratosphere.
+// Every field left to 0 takes the ISA value.
+type Atmosphere struct {
+	SeaLevelTemperature float64 `json:"seaLevelTemperature"` // Kelvin
+	SeaLevelPressure    float64 `json:"seaLevelPressure"`    // Pascals
+	LapseRate           float64 `json:"lapseRate"`           // Kelvin / meter in the troposphere
+	TropopauseAltitude  float64 `json:"tropopauseAltitude"`  // meters
+	GroundAltitude      float64 `json:"groundAltitude"`      // Altitude (above sea level) of Y = 0 in the map
+	Wind                Wind    `json:"wind"`
+}
+
+// withDefaults returns the atmosphere with ISA values in place of the missing ones
+func (a Atmosphere) withDefaults() Atmosphere {
+
+	if a.SeaLevelTemperature == 0 {
+		a.SeaLevelTemperature = isaSeaLevelTemperature
+	}
+	if a.SeaLevelPressure == 0 {
+		a.SeaLevelPressure = isaSeaLevelPressure
+	}
+	if a.LapseRate == 0 {
+		a.LapseRate = isaLapseRate
+	}
+	if a.TropopauseAltitude == 0 {
+		a.TropopauseAltitude = isaTropopauseAltitude
+	}
+	a.Wind = a.Wind.withDefaults()
+	return a
+}
+
+// update makes the atmosphere evolve over time
+func (a *Atmosphere) update(deltaT float64) {
+	a.Wind.update(deltaT)
+}
+
+// Temperature returns the temperature (Kelvin) at "y" in the map
+func (a *Atmosphere) Temperature(y float64) float64 {
+	altitude := math.Min(a.GroundAltitude+y, a.TropopauseAltitude)
+	return a.SeaLevelTemperature - a.LapseRate*altitude
+}
+
+// Pressure returns the pressure (Pascals) at "y" in the map
+func (a *Atmosphere) Pressure(y float64) float64 {
+
+	altitude := a.GroundAltitude + y
+	exponent := standardGravity / (gasConstant * a.LapseRate)
+
+	if altitude <= a.TropopauseAltitude {
+		return a.SeaLevelPressure * math.Pow(a.Temperature(y)/a.SeaLevelTemperature, exponent)
+	}
+
+	// Isothermal layer above the tropopause
+	tropopauseTemperature := a.SeaLevelTemperature - a.LapseRate*a.TropopauseAltitude
+	tropopausePressure := a.SeaLevelPressure * math.Pow(tropopauseTemperature/a.SeaLevelTemperature, exponent)
+
+	return tropopausePressure * math.Exp(-standardGravity*(altitude-a.TropopauseAltitude)/(gasConstant*tropopauseTemperature))
+}
+
+// Density returns the air density (kg / m³) at "y" in the map
+func (a *Atmosphere) Density(y float64) float64 {
+	return a.Pressure(y) / (gasConstant * a.Temperature(y))
+}
+
+// SpeedOfSound returns the speed of sound (m / s) at "y" in the map
+func (a *Atmosphere) SpeedOfSound(y float64) float64 {
+	return math.Sqrt(heatCapacityRatio * gasConstant * a.Temperature(y))
+}
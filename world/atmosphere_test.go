@@ -0,0 +1,49 @@
+package world
+
+import (
+	"math"
+	"testing"
+)
+
+func isClose(a float64, b float64, tolerance float64) bool {
+	return math.Abs(a-b) <= tolerance
+}
+
+func TestStandardAtmosphere(t *testing.T) {
+	a := Atmosphere{}.withDefaults()
+
+	// Reference values of the ISA tables
+	values := []struct {
+		altitude, temperature, pressure, density float64
+	}{
+		{0, 288.15, 101325, 1.2250},
+		{5000, 255.65, 54020, 0.7361},
+		{11000, 216.65, 22632, 0.3639},
+		{15000, 216.65, 12045, 0.1937},
+	}
+
+	for _, v := range values {
+		if !isClose(a.Temperature(v.altitude), v.temperature, 0.01) {
+			t.Errorf("Temperature at %.0fm: %f instead of %f", v.altitude, a.Temperature(v.altitude), v.temperature)
+		}
+		if !isClose(a.Pressure(v.altitude), v.pressure, v.pressure*0.001) {
+			t.Errorf("Pressure at %.0fm: %f instead of %f", v.altitude, a.Pressure(v.altitude), v.pressure)
+		}
+		if !isClose(a.Density(v.altitude), v.density, 0.001) {
+			t.Errorf("Density at %.0fm: %f instead of %f", v.altitude, a.Density(v.altitude), v.density)
+		}
+	}
+
+	if !isClose(a.SpeedOfSound(0), 340.29, 0.01) {
+		t.Errorf("Speed of sound at sea level: %f", a.SpeedOfSound(0))
+	}
+}
+
+func TestHighAltitudeMap(t *testing.T) {
+	standard := Atmosphere{}.withDefaults()
+	high := Atmosphere{GroundAltitude: 3000}.withDefaults()
+
+	if !isClose(high.Density(0), standard.Density(3000), 1e-9) {
+		t.Errorf("The density at the bottom of the map should be the one at 3000m: %f", high.Density(0))
+	}
+}
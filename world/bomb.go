@@ -0,0 +1,235 @@
+package world
+
+import (
+	"encoding/binary"
+	"math"
+
+	"github.com/eaglesight/eaglesight-server/mathutils"
+)
+
+const (
+	// ProjectileBomb is the type of the bombs in the snapshots
+	ProjectileBomb = 0x5
+	// bombReleaseInterval is the minimum time in seconds between two bombs dropped by a plane
+	bombReleaseInterval = 0.5
+	// bombDrag is the drag of a bomb divided by its mass, at sea level
+	bombDrag = 0.00005
+	// bombLifetime is the time in seconds after which a bomb that didn't hit anything is removed (out of the map...)
+	bombLifetime = 120
+	// defaultBlastRadius is used when the model doesn't set one
+	defaultBlastRadius = 20
+)
+
+// BombModel are the constant properties of a bomb. They are part of a PlaneModel
+type BombModel struct {
+	Name        string  `json:"name"`
+	Count       uint8   `json:"count"`       // Bombs carried at spawn
+	Damage      uint8   `json:"damage"`      // Damage at the center of the blast
+	BlastRadius float64 `json:"blastRadius"` // The damage decreases linearly to 0 at this distance. 0 means defaultBlastRadius
+}
+
+// withDefaults returns the model with the default blast radius if it has none
+func (m BombModel) withDefaults() BombModel {
+
+	if m.BlastRadius <= 0 {
+		m.BlastRadius = defaultBlastRadius
+	}
+	return m
+}
+
+// BombBay is the state of the bombs of a type carried by a plane
+type BombBay struct {
+	model BombModel
+	count uint8
+}
+
+// Bomb is dropped by a plane and falls until it hits something
+type Bomb struct {
+	id       uint16
+	source   uint8
+	model    BombModel
+	location mathutils.Vector3D
+	previous mathutils.Vector3D // Location before the last update
+	speed    mathutils.Vector3D
+	age      float64
+}
+
+// NewBomb creates a bomb at origin, moving at velocity (global space)
+func NewBomb(source uint8, model BombModel, origin mathutils.Vector3D, velocity mathutils.Vector3D) *Bomb {
+
+	return &Bomb{
+		source:   source,
+		model:    model.withDefaults(),
+		location: origin,
+		previous: origin,
+		speed:    velocity,
+	}
+}
+
+// Update moves the bomb. Returns whether the bomb is still "living"
+func (b *Bomb) Update(deltaT float64, atmosphere *Atmosphere) bool {
+
+	b.previous = b.location
+	b.age += deltaT
+
+	airspeed := b.speed.Sub(atmosphere.Wind.At(b.location))
+	densityRatio := atmosphere.Density(b.location.Y) / SeaLevelDensity
+	acceleration := airspeed.MulScalar(-bombDrag * densityRatio * airspeed.Length())
+	acceleration.Y -= standardGravity
+
+	b.speed = b.speed.Add(acceleration.MulScalar(deltaT))
+	b.location = b.location.Add(b.speed.MulScalar(deltaT))
+
+	return b.age < bombLifetime
+}
+
+// Read writes the state of the bomb in the snapshot, like a projectile's spawn record
+func (b *Bomb) Read(snapshot []byte) (n int, err error) {
+
+	binary.BigEndian.PutUint16(snapshot[0:], b.id)
+	snapshot[2] = ProjectileBomb
+	snapshot[3] = b.source
+	putVector3D(snapshot[4:], b.location)
+	putVector3D(snapshot[16:], b.speed)
+
+	return ProjectileSnapshotSize, nil
+}
+
+// bombBay returns the first bomb bay of the plane that still has bombs
+func (p *Plane) bombBay() *BombBay {
+	for _, bay := range p.bombBays {
+		if bay.count > 0 {
+			return bay
+		}
+	}
+	return nil
+}
+
+// dropBombs drops a bomb for every plane that asks for it
+func (w *World) dropBombs(deltaT float64) {
+
+	for _, plane := range w.sortedPlanes() {
+
+		plane.bombCooldown -= deltaT
+
+		bay := plane.bombBay()
+
+		if !plane.input.IsBombing || plane.isDead() || bay == nil || plane.bombCooldown > 0 {
+			continue
+		}
+
+		bay.count--
+		plane.bombCooldown = bombReleaseInterval
+
+		w.addBomb(NewBomb(plane.UID, bay.model, plane.location, plane.speed))
+	}
+}
+
+// addBomb adds the bomb in the world. It shares the ids of the bullets
+func (w *World) addBomb(bomb *Bomb) {
+
+	bomb.id = w.nextProjectileID
+	w.nextProjectileID++
+
+	w.bombs = append(w.bombs, bomb)
+}
+
+// updateBombs moves the bombs and removes the ones that fell for too long
+func (w *World) updateBombs(deltaT float64) {
+
+	bombsStillAlive := []*Bomb{}
+
+	for _, bomb := range w.bombs {
+
+		if bomb.Update(deltaT, w.atmosphere) {
+			bombsStillAlive = append(bombsStillAlive, bomb)
+		} else {
+			w.despawned = append(w.despawned, bomb.id)
+		}
+	}
+	w.bombs = bombsStillAlive
+}
+
+// detectBlasts explodes the bombs that hit the ground, a target or a plane during the last tick
+func (w *World) detectBlasts() {
+
+	bombsStillAlive := []*Bomb{}
+
+	for _, bomb := range w.bombs {
+
+		if location, hit := w.sweepBomb(bomb); hit {
+			w.explode(bomb, location)
+			w.despawned = append(w.despawned, bomb.id)
+			continue
+		}
+		bombsStillAlive = append(bombsStillAlive, bomb)
+	}
+	w.bombs = bombsStillAlive
+}
+
+// sweepBomb returns where the bomb first hit something along its last move
+func (w *World) sweepBomb(bomb *Bomb) (location mathutils.Vector3D, hit bool) {
+
+	earliest := math.Inf(1)
+
+	for _, entity := range w.grid.alongSegment(bomb.previous, bomb.location, 0) {
+
+		target := entity.(damageable)
+
+		// The bomb doesn't explode in the bay
+		if plane, isPlane := target.(*Plane); target.isDead() || (isPlane && plane.UID == bomb.source) {
+			continue
+		}
+
+		if t, touched := mathutils.SegmentSphereIntersection(bomb.previous, bomb.location, target.position(), target.hitRadius()); touched && t < earliest {
+			earliest = t
+			hit = true
+		}
+	}
+
+	if w.terrain != nil {
+		if t, _, touched := w.terrain.SegmentIntersection(bomb.previous, bomb.location); touched && t < earliest {
+			earliest = t
+			hit = true
+		}
+	}
+
+	if !hit {
+		return location, false
+	}
+	return mathutils.Lerp(bomb.previous, bomb.location, earliest), true
+}
+
+// explode damages everything in the blast of the bomb. The closer, the more damage
+func (w *World) explode(bomb *Bomb, location mathutils.Vector3D) {
+
+	w.emit(explosionMessage(bomb.source, location, bomb.model.BlastRadius))
+
+	for _, entity := range w.grid.withinRadius(location, bomb.model.BlastRadius) {
+
+		target := entity.(damageable)
+		if target.isDead() {
+			continue
+		}
+
+		// Distance to the surface of the target
+		distance := math.Max(0, mathutils.Distance(location, target.position())-target.hitRadius())
+		damage := float64(bomb.model.Damage) * (1 - distance/bomb.model.BlastRadius)
+
+		if damage >= 1 {
+			w.hurt(bomb.source, target, uint8(damage))
+		}
+	}
+}
+
+// explosionMessage tells the players that a bomb exploded:
+// 0xC|uid of the plane that dropped it|location (float32 * 3)|blast radius (float32)
+func explosionMessage(source uint8, location mathutils.Vector3D, radius float64) []byte {
+
+	message := make([]byte, 1+1+3*4+4)
+	message[0] = 0xC // Explosion
+	message[1] = source
+	putVector3D(message[2:], location)
+	binary.BigEndian.PutUint32(message[14:], math.Float32bits(float32(radius)))
+	return message
+}
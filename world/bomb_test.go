@@ -0,0 +1,174 @@
+package world
+
+import (
+	"testing"
+
+	"github.com/eaglesight/eaglesight-server/mathutils"
+)
+
+func dummyBombModel() BombModel {
+	return BombModel{
+		Count:       2,
+		Damage:      200,
+		BlastRadius: 50,
+	}
+}
+
+// targetWorld returns a world with a bunker and a truck 45 units away
+func targetWorld() *World {
+	w := getTestWorld()
+	w.scenario.Targets = []GroundTargetModel{
+		{Name: "bunker", Kind: "building", Location: mathutils.Vector3D{X: 1000, Y: 0, Z: 1000}, Life: 300, HitRadius: 10},
+		{Name: "truck", Kind: "vehicle", Location: mathutils.Vector3D{X: 1045, Y: 0, Z: 1000}, Life: 50, HitRadius: 4},
+	}
+	w.placeTargets()
+	return w
+}
+
+func TestPlaceTargets(t *testing.T) {
+	w := targetWorld()
+
+	bunker := w.targets[0]
+	if !isClose(bunker.location.Y, w.terrain.HeightAt(bunker.location), 1e-9) || bunker.kind != TargetBuilding || bunker.life != 300 {
+		t.Errorf("The bunker should be on the ground: %+v", bunker)
+	}
+
+	// A newcomer is told about the targets
+	w.addPlane(1, 0, PlaneModel{}, w.gun)
+	targets := eventsWithOpcode(w, 0xD)
+	if len(targets) != 1 || targets[0][1] != 2 || targets[0][2+1] != TargetBuilding || targets[0][2+15+1] != TargetVehicle {
+		t.Errorf("Wrong targets message: %v", targets)
+	}
+}
+
+func TestPlaceTooManyTargets(t *testing.T) {
+	w := getTestWorld()
+
+	for i := 0; i < MaxTargets+10; i++ {
+		w.scenario.Targets = append(w.scenario.Targets, GroundTargetModel{Name: "hut", Kind: "hut", Life: 10})
+	}
+	w.placeTargets()
+
+	if len(w.targets) != MaxTargets || w.targets[MaxTargets-1].id != MaxTargets-1 {
+		t.Errorf("Only %d targets should be placed, not %d", MaxTargets, len(w.targets))
+	}
+
+	// An unknown kind is a building
+	if w.targets[0].kind != TargetBuilding {
+		t.Errorf("The kind should be a building, not %d", w.targets[0].kind)
+	}
+}
+
+func TestDropBombs(t *testing.T) {
+	w := getTestWorld()
+	w.addPlane(1, 0, PlaneModel{Life: 100, Bombs: []BombModel{dummyBombModel()}}, w.gun)
+	plane := w.planes[1]
+
+	plane.Write([]byte{0x3, 0, 0, 0, 0, 0x10})
+	if !plane.input.IsBombing || plane.input.IsDeploying {
+		t.Fatalf("Wrong input: %+v", plane.input)
+	}
+
+	w.dropBombs(0.01)
+	// Too soon
+	w.dropBombs(0.01)
+	w.dropBombs(1)
+	// Empty
+	w.dropBombs(1)
+
+	if len(w.bombs) != 2 || plane.bombBays[0].count != 0 {
+		t.Errorf("2 bombs should be dropped, not %d", len(w.bombs))
+	}
+
+	// The bomb doesn't explode in the bay
+	w.indexEntities()
+	w.detectBlasts()
+
+	if len(w.bombs) != 2 || plane.life != 100 {
+		t.Error("The bombs should not explode next to the plane")
+	}
+}
+
+func TestBombFalls(t *testing.T) {
+	atmosphere := Atmosphere{}.withDefaults()
+	bomb := NewBomb(1, dummyBombModel(), mathutils.Vector3D{X: 0, Y: 1000, Z: 0}, mathutils.Vector3D{X: 0, Y: 0, Z: 100})
+
+	for i := 0; i < 1000; i++ {
+		bomb.Update(0.01, &atmosphere)
+	}
+
+	// A bit less than 1/2 * g * t^2 down and 100 * t forward after 10 seconds, because of the drag
+	if bomb.location.Y < 1000-0.5*standardGravity*100 || bomb.location.Y > 530 || bomb.location.Z > 1000 || bomb.location.Z < 950 {
+		t.Errorf("The bomb should follow a ballistic path: %+v", bomb.location)
+	}
+}
+
+func TestBombSplash(t *testing.T) {
+	w := targetWorld()
+	bunker := w.targets[0]
+	truck := w.targets[1]
+
+	// Right on the bunker
+	above := bunker.location.Add(mathutils.Vector3D{X: 0, Y: 20, Z: 0})
+	bomb := NewBomb(1, dummyBombModel(), above, mathutils.Vector3D{})
+	bomb.location = bunker.location
+	w.addBomb(bomb)
+
+	w.indexEntities()
+	w.detectBlasts()
+
+	if len(w.bombs) != 0 || len(eventsWithOpcode(w, 0xC)) != 1 {
+		t.Fatal("The bomb should explode")
+	}
+
+	// The bunker takes full damage, the truck is farther
+	if bunker.life != 100 {
+		t.Errorf("The bunker's life should be 100, not %d", bunker.life)
+	}
+
+	if truck.life == 50 || truck.isDead() {
+		t.Errorf("The truck should be damaged by the splash: %d", truck.life)
+	}
+
+	w.reportTargets()
+	if len(eventsWithOpcode(w, 0xD)) != 1 {
+		t.Error("The damage should be reported")
+	}
+}
+
+func TestBombWithoutBlastRadius(t *testing.T) {
+	w := targetWorld()
+	bunker := w.targets[0]
+
+	model := dummyBombModel()
+	model.BlastRadius = 0
+	bomb := NewBomb(1, model, bunker.location, mathutils.Vector3D{})
+
+	w.indexEntities()
+	w.explode(bomb, bunker.location)
+
+	if bunker.life != 100 {
+		t.Errorf("A direct hit should do full damage: life %d", bunker.life)
+	}
+}
+
+func TestShootTarget(t *testing.T) {
+	w := targetWorld()
+	truck := w.targets[1]
+
+	for i := 0; i < 5; i++ {
+		w.addBullet(&Bullet{
+			source:   1,
+			previous: truck.location.Add(mathutils.Vector3D{X: 0, Y: 20, Z: -20}),
+			location: truck.location,
+			damage:   10,
+		})
+	}
+
+	w.indexEntities()
+	w.detectHits(0.01)
+
+	if !truck.isDead() {
+		t.Errorf("The truck should be destroyed, its life is %d", truck.life)
+	}
+}
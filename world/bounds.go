@@ -0,0 +1,130 @@
+package world
+
+import (
+	"encoding/binary"
+	"math"
+
+	"github.com/eaglesight/eaglesight-server/mathutils"
+)
+
+// defaultOutOfBoundsTimeout is used when the rules don't set one (seconds)
+const defaultOutOfBoundsTimeout = 10
+
+// Zones of the battle area reported in the bounds messages
+const (
+	// ZoneInside : the plane is in the battle area
+	ZoneInside = 0x0
+	// ZoneWarning : the plane is close to the border
+	ZoneWarning = 0x1
+	// ZoneOutside : the plane left the battle area. It must come back before the timeout
+	ZoneOutside = 0x2
+)
+
+// Bounds is the battle area. The planes that leave it are penalized
+type Bounds struct {
+	Min     mathutils.Vector3D `json:"min"`
+	Max     mathutils.Vector3D `json:"max"`     // The altitude is not limited when Max.Y isn't above Min.Y
+	Warning float64            `json:"warning"` // Width of the warning zone along the border
+}
+
+// withDefaults returns the bounds with the area of the terrain if none is set.
+// Without terrain, the area has no limit
+func (b Bounds) withDefaults(terrain *Terrain) Bounds {
+
+	if (b.Max.X <= b.Min.X || b.Max.Z <= b.Min.Z) && terrain == nil {
+		b.Min.X, b.Min.Z = math.Inf(-1), math.Inf(-1)
+		b.Max.X, b.Max.Z = math.Inf(1), math.Inf(1)
+	}
+
+	if b.Max.X <= b.Min.X || b.Max.Z <= b.Min.Z {
+		b.Min.X, b.Min.Z = 0, 0
+		b.Max.X = float64(terrain.width-1) * terrain.distance
+		b.Max.Z = float64(terrain.depth-1) * terrain.distance
+	}
+
+	if b.Max.Y <= b.Min.Y {
+		b.Min.Y = math.Inf(-1)
+		b.Max.Y = math.Inf(1)
+	}
+	return b
+}
+
+// distanceToBorder returns how far the location is from the closest border. It's negative outside
+func (b *Bounds) distanceToBorder(location mathutils.Vector3D) float64 {
+
+	return math.Min(
+		math.Min(
+			math.Min(location.X-b.Min.X, b.Max.X-location.X),
+			math.Min(location.Z-b.Min.Z, b.Max.Z-location.Z)),
+		math.Min(location.Y-b.Min.Y, b.Max.Y-location.Y))
+}
+
+// clamp returns the closest location in the battle area
+func (b *Bounds) clamp(location mathutils.Vector3D) mathutils.Vector3D {
+
+	location.X = math.Max(b.Min.X, math.Min(location.X, b.Max.X))
+	location.Y = math.Max(b.Min.Y, math.Min(location.Y, b.Max.Y))
+	location.Z = math.Max(b.Min.Z, math.Min(location.Z, b.Max.Z))
+	return location
+}
+
+// zoneOf returns in which zone of the battle area the location is
+func (b *Bounds) zoneOf(location mathutils.Vector3D) uint8 {
+
+	switch distance := b.distanceToBorder(location); {
+	case distance < 0 || math.IsNaN(distance):
+		return ZoneOutside
+	case distance < b.Warning:
+		return ZoneWarning
+	default:
+		return ZoneInside
+	}
+}
+
+// checkBounds warns the plane when it changes zone, and penalizes it when it stays out for too long
+func (w *World) checkBounds(plane *Plane, deltaT float64) {
+
+	zone := w.bounds.zoneOf(plane.location)
+
+	if zone == ZoneOutside {
+		plane.timeOutside += deltaT
+	} else {
+		plane.timeOutside = 0
+	}
+
+	timeout := w.rules.OutOfBoundsTimeout
+	if timeout <= 0 {
+		timeout = defaultOutOfBoundsTimeout
+	}
+
+	if zone != plane.zone {
+		plane.zone = zone
+		w.emitTo(plane.UID, boundsMessage(zone, timeout))
+	}
+
+	if plane.timeOutside < timeout {
+		return
+	}
+
+	if w.rules.OutOfBoundsDamage == 0 {
+		plane.destroy(PlaneOutOfBounds)
+		return
+	}
+
+	// Damage every second after the timeout, starting right away
+	before := math.Floor(plane.timeOutside - deltaT - timeout)
+	if math.Floor(plane.timeOutside-timeout) > before {
+		plane.takeDamage(w.rules.OutOfBoundsDamage, PlaneOutOfBounds)
+	}
+}
+
+// boundsMessage tells a player in which zone of the battle area the plane is:
+// 0xA|zone|seconds before the penalty once outside (float32)
+func boundsMessage(zone uint8, timeout float64) []byte {
+
+	message := make([]byte, 1+1+4)
+	message[0] = 0xA // Bounds
+	message[1] = zone
+	binary.BigEndian.PutUint32(message[2:], math.Float32bits(float32(timeout)))
+	return message
+}
@@ -0,0 +1,120 @@
+package world
+
+import (
+	"math"
+	"testing"
+
+	"github.com/eaglesight/eaglesight-server/mathutils"
+)
+
+func TestBoundsDefaults(t *testing.T) {
+	terrain := ridgeTerrain(8, 100)
+	bounds := Bounds{}.withDefaults(terrain)
+
+	if bounds.Max.X != 150 || bounds.Max.Z != 150 || !math.IsInf(bounds.Max.Y, 1) {
+		t.Errorf("The bounds should be the terrain: %+v", bounds)
+	}
+}
+
+func TestBoundsWithoutTerrain(t *testing.T) {
+	w := NewWorld(nil, Scenario{}, Rules{})
+
+	if zone := w.bounds.zoneOf(mathutils.Vector3D{X: -1e6, Y: 1500, Z: 1e6}); zone != ZoneInside {
+		t.Errorf("Without terrain, there is no battle area: %+v", w.bounds)
+	}
+}
+
+func TestZoneOf(t *testing.T) {
+	bounds := Bounds{
+		Min:     mathutils.Vector3D{X: 0, Y: 0, Z: 0},
+		Max:     mathutils.Vector3D{X: 1000, Y: 0, Z: 1000},
+		Warning: 100,
+	}.withDefaults(nil)
+
+	zones := []struct {
+		location mathutils.Vector3D
+		zone     uint8
+	}{
+		{mathutils.Vector3D{X: 500, Y: 1500, Z: 500}, ZoneInside},
+		{mathutils.Vector3D{X: 950, Y: 1500, Z: 500}, ZoneWarning},
+		{mathutils.Vector3D{X: 500, Y: 1500, Z: 20}, ZoneWarning},
+		{mathutils.Vector3D{X: -10, Y: 1500, Z: 500}, ZoneOutside},
+		{mathutils.Vector3D{X: 500, Y: 1500, Z: 1001}, ZoneOutside},
+	}
+
+	for _, expected := range zones {
+		if zone := bounds.zoneOf(expected.location); zone != expected.zone {
+			t.Errorf("%+v should be in the zone %d, not %d", expected.location, expected.zone, zone)
+		}
+	}
+}
+
+func TestOutOfBoundsDestroyed(t *testing.T) {
+	w := getTestWorld()
+	w.rules.OutOfBoundsTimeout = 2
+	w.addPlane(1, 0, PlaneModel{Life: 100}, w.gun)
+	plane := w.planes[1]
+
+	plane.location.X = -100
+	w.checkBounds(plane, 0.5)
+
+	warnings := eventsWithOpcode(w, 0xA)
+	if len(warnings) != 1 || warnings[0][1] != ZoneOutside || !w.events[len(w.events)-1].Private {
+		t.Errorf("The player should be told to come back: %v", warnings)
+	}
+
+	w.checkBounds(plane, 1)
+	if plane.isDead() {
+		t.Error("The plane should still have some time")
+	}
+
+	w.checkBounds(plane, 1)
+	if !plane.isDead() || plane.cause != PlaneOutOfBounds {
+		t.Error("The plane should be destroyed after the timeout")
+	}
+}
+
+func TestOutOfBoundsDamage(t *testing.T) {
+	w := getTestWorld()
+	w.rules.OutOfBoundsTimeout = 1
+	w.rules.OutOfBoundsDamage = 10
+	w.addPlane(1, 0, PlaneModel{Life: 100}, w.gun)
+	plane := w.planes[1]
+
+	plane.location.Z = -100
+	// 3.5 seconds outside: the timeout, then damage at 1s, 2s and 3s
+	for i := 0; i < 350; i++ {
+		w.checkBounds(plane, 0.01)
+	}
+
+	if plane.life != 70 {
+		t.Errorf("The plane should be damaged every second, its life is %d", plane.life)
+	}
+
+	// Back in the battle area
+	plane.location = mathutils.Vector3D{X: 2000, Y: 1500, Z: 2000}
+	w.checkBounds(plane, 5)
+
+	if plane.timeOutside != 0 || plane.zone != ZoneInside {
+		t.Error("The plane should be back")
+	}
+}
+
+func TestNegativeCoordinates(t *testing.T) {
+	terrain := ridgeTerrain(8, 100)
+
+	triangle := terrain.OverredTriangle(mathutils.Vector3D{X: -5, Y: 0, Z: 12})
+	if !math.IsNaN(triangle[0].X) {
+		t.Errorf("A negative position is out of bound: %+v", triangle)
+	}
+
+	// The ground continues beyond the edge of the map
+	plane, _ := dummyPlane(1)
+	plane.location = mathutils.Vector3D{X: -500, Y: -20, Z: 12}
+	plane.speed = mathutils.Vector3D{}
+	plane.CorrectFromCollision(terrain)
+
+	if plane.location.Y < 0 {
+		t.Errorf("The plane should be above the ground: %+v", plane.location)
+	}
+}
@@ -1,40 +1,86 @@
 package world
 
 import (
+	"encoding/binary"
+	"math"
+
 	"github.com/eaglesight/eaglesight-server/mathutils"
 )
 
+const (
+	// ProjectileSnapshotSize : uint16 (id) + uint8 (type) + uint8 (owner's uid) + float32 * 3 (location) + float32 * 3 (speed)
+	ProjectileSnapshotSize = 2 + 1 + 1 + (3 * 4) + (3 * 4)
+	// ProjectileBullet is the type of the bullets in the snapshots
+	ProjectileBullet = 0x1
+)
+
 // Bullet represent a bullet
 type Bullet struct {
-	source      uint8              // UID of the player that shot the bullet
-	location    mathutils.Vector3D // Location in global space
-	speed       mathutils.Vector3D // Speed in global space
-	damage      uint8              // Amount of damage the bullet make on impact
-	ticksToLive uint16
+	id         uint16             // Identify the bullet in the snapshots
+	announced  bool               // The bullet's spawn has been sent in a snapshot
+	source     uint8              // UID of the player that shot the bullet
+	location   mathutils.Vector3D // Location in global space
+	previous   mathutils.Vector3D // Location before the last update
+	speed      mathutils.Vector3D // Speed in global space
+	damage     uint8              // Amount of damage the bullet make on impact
+	timeToLive float64            // Seconds before the bullet disappears
 }
 
-// NewBullet create a new bullet
-func NewBullet(source uint8, origin mathutils.Vector3D, direction *mathutils.Matrix3, speed float64, damage uint8) *Bullet {
+const (
+	// defaultBulletLifetime is used when the gun doesn't set one (seconds)
+	defaultBulletLifetime = 5
+	// bulletDrag is the drag of a bullet divided by its mass, at sea level
+	bulletDrag = 0.0005
+)
 
-	const BulletLifetime = 500 // Bullet will live for 500 updates. That might be tweaked in the future
-	speedVector := mathutils.Vector3D{X: 0, Y: speed, Z: 0}
+// NewBullet create a new bullet at origin, moving at velocity (global space). It lives for "lifetime" seconds
+func NewBullet(source uint8, origin mathutils.Vector3D, velocity mathutils.Vector3D, damage uint8, lifetime float64) *Bullet {
 
 	return &Bullet{
-		source:      source,
-		location:    origin,
-		speed:       speedVector.MultiplyByMatrix3(direction),
-		damage:      damage,
-		ticksToLive: BulletLifetime,
+		source:     source,
+		location:   origin,
+		previous:   origin,
+		speed:      velocity,
+		damage:     damage,
+		timeToLive: lifetime,
 	}
 }
 
 // Update updates the state of the bullet. Returns whether the bullet is still "living"
-func (b *Bullet) Update(deltaT float64) bool {
+func (b *Bullet) Update(deltaT float64, atmosphere *Atmosphere) bool {
+
+	b.previous = b.location
 
+	// The air slows the bullet down and carries it with the wind. Thinner air means less drag
+	airspeed := b.speed.Sub(atmosphere.Wind.At(b.location))
+	densityRatio := atmosphere.Density(b.location.Y) / SeaLevelDensity
+	acceleration := airspeed.MulScalar(-bulletDrag * densityRatio * airspeed.Length())
 	// Apply some gravity
-	b.speed = b.speed.Add(mathutils.Vector3D{X: 0, Y: -9.8 * deltaT, Z: 0})
-	b.location = b.location.Add(b.speed)
-	// One tick closer to death...
-	b.ticksToLive--
-	return b.ticksToLive > 0
+	acceleration.Y -= standardGravity
+
+	// Semi-implicit Euler, like the planes
+	b.speed = b.speed.Add(acceleration.MulScalar(deltaT))
+	b.location = b.location.Add(b.speed.MulScalar(deltaT))
+
+	// A bit closer to death...
+	b.timeToLive -= deltaT
+	return b.timeToLive > 0
+}
+
+// Read writes the spawn record of the bullet in the snapshot
+func (b *Bullet) Read(snapshot []byte) (n int, err error) {
+
+	binary.BigEndian.PutUint16(snapshot[0:], b.id)
+	snapshot[2] = ProjectileBullet
+	snapshot[3] = b.source
+	// Location
+	binary.BigEndian.PutUint32(snapshot[4:], math.Float32bits(float32(b.location.X)))
+	binary.BigEndian.PutUint32(snapshot[8:], math.Float32bits(float32(b.location.Y)))
+	binary.BigEndian.PutUint32(snapshot[12:], math.Float32bits(float32(b.location.Z)))
+	// Speed
+	binary.BigEndian.PutUint32(snapshot[16:], math.Float32bits(float32(b.speed.X)))
+	binary.BigEndian.PutUint32(snapshot[20:], math.Float32bits(float32(b.speed.Y)))
+	binary.BigEndian.PutUint32(snapshot[24:], math.Float32bits(float32(b.speed.Z)))
+
+	return ProjectileSnapshotSize, nil
 }
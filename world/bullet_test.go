@@ -1,6 +1,8 @@
 package world
 
 import (
+	"encoding/binary"
+	"math"
 	"testing"
 
 	"github.com/eaglesight/eaglesight-server/mathutils"
@@ -12,10 +14,9 @@ func TestNewBullet(t *testing.T) {
 		Y: 3,
 		Z: 2,
 	}
-	direction := mathutils.NewMatrix3()
-	bullet := NewBullet(3, origin, &direction, 400, 12)
+	bullet := NewBullet(3, origin, mathutils.Vector3D{X: 0, Y: 0, Z: 400}, 12, 2)
 
-	if bullet.speed.Y != 400 {
+	if bullet.speed.Z != 400 || bullet.timeToLive != 2 {
 		t.Fail()
 	}
 }
@@ -26,16 +27,99 @@ func TestBulletUpdate(t *testing.T) {
 		Y: 0,
 		Z: 0,
 	}
-	direction := mathutils.NewMatrix3()
-	bullet := NewBullet(3, origin, &direction, 400, 12)
+	bullet := NewBullet(3, origin, mathutils.Vector3D{X: 0, Y: 0, Z: 400}, 12, 2)
 
-	if bullet.location.Y != 0 {
+	if bullet.location.Z != 0 {
 		t.Errorf("Bullet's location is %+v", bullet.location)
 	}
 
-	bullet.Update(1)
+	atmosphere := Atmosphere{}.withDefaults()
+	bullet.Update(0.01, &atmosphere)
 
-	if bullet.location.Y == 0 {
+	// About 4 units forward, slowed down by the air and pulled down by gravity
+	if bullet.location.Z <= 3.9 || bullet.location.Z >= 4 || bullet.location.Y >= 0 {
 		t.Errorf("Bullet's location is %+v", bullet.location)
 	}
 }
+
+func TestBulletTickRate(t *testing.T) {
+	atmosphere := Atmosphere{}.withDefaults()
+
+	// One second at 100Hz and at 20Hz should end up at about the same place
+	fast := NewBullet(3, mathutils.Vector3D{}, mathutils.Vector3D{X: 0, Y: 0, Z: 600}, 12, 2)
+	for i := 0; i < 100; i++ {
+		fast.Update(0.01, &atmosphere)
+	}
+
+	slow := NewBullet(3, mathutils.Vector3D{}, mathutils.Vector3D{X: 0, Y: 0, Z: 600}, 12, 2)
+	for i := 0; i < 20; i++ {
+		slow.Update(0.05, &atmosphere)
+	}
+
+	if mathutils.Distance(fast.location, slow.location) > 5 {
+		t.Errorf("The tick rate changes the trajectory: %+v and %+v", fast.location, slow.location)
+	}
+}
+
+func TestBulletLifetime(t *testing.T) {
+	atmosphere := Atmosphere{}.withDefaults()
+	bullet := NewBullet(3, mathutils.Vector3D{}, mathutils.Vector3D{X: 0, Y: 0, Z: 600}, 12, 1)
+
+	for i := 0; i < 99; i++ {
+		if !bullet.Update(0.01, &atmosphere) {
+			t.Fatalf("The bullet died after %d updates", i+1)
+		}
+	}
+
+	if bullet.Update(0.01, &atmosphere) {
+		t.Error("The bullet should be dead after 1 second")
+	}
+}
+
+func TestBulletDragWithAltitude(t *testing.T) {
+	atmosphere := Atmosphere{}.withDefaults()
+
+	low := NewBullet(3, mathutils.Vector3D{}, mathutils.Vector3D{X: 0, Y: 0, Z: 600}, 12, 2)
+	high := NewBullet(3, mathutils.Vector3D{X: 0, Y: 10000, Z: 0}, mathutils.Vector3D{X: 0, Y: 0, Z: 600}, 12, 2)
+
+	low.Update(0.01, &atmosphere)
+	high.Update(0.01, &atmosphere)
+
+	if high.speed.Z <= low.speed.Z {
+		t.Errorf("The bullet should be slowed down less in thin air: %f vs %f", high.speed.Z, low.speed.Z)
+	}
+}
+
+func TestBulletWindDrift(t *testing.T) {
+	atmosphere := Atmosphere{Wind: Wind{Base: mathutils.Vector3D{X: 20, Y: 0, Z: 0}}}.withDefaults()
+
+	bullet := NewBullet(3, mathutils.Vector3D{}, mathutils.Vector3D{X: 0, Y: 0, Z: 400}, 12, 2)
+
+	for i := 0; i < 100; i++ {
+		bullet.Update(0.01, &atmosphere)
+	}
+
+	if bullet.speed.X <= 0 {
+		t.Errorf("The bullet should drift with the wind: %+v", bullet.speed)
+	}
+}
+
+func TestBulletRead(t *testing.T) {
+	bullet := NewBullet(3, mathutils.Vector3D{X: 1, Y: 2, Z: 3}, mathutils.Vector3D{X: 0, Y: 400, Z: 0}, 12, 2)
+	bullet.id = 513
+
+	snap := make([]byte, ProjectileSnapshotSize)
+	bullet.Read(snap)
+
+	if binary.BigEndian.Uint16(snap[0:2]) != 513 || snap[2] != ProjectileBullet || snap[3] != 3 {
+		t.Errorf("Wrong header: %v", snap[0:4])
+	}
+
+	if math.Float32frombits(binary.BigEndian.Uint32(snap[8:12])) != 2 {
+		t.Errorf("Wrong location: %v", snap[4:16])
+	}
+
+	if math.Float32frombits(binary.BigEndian.Uint32(snap[20:24])) != float32(bullet.speed.Y) {
+		t.Errorf("Wrong speed: %v", snap[16:28])
+	}
+}
@@ -0,0 +1,70 @@
+package world
+
+import (
+	"github.com/eaglesight/eaglesight-server/mathutils"
+)
+
+// planesPair identifies two planes that touch each other. The lowest UID comes first
+type planesPair [2]uint8
+
+// detectCollisions checks every plane against the planes around it.
+// Two planes that collide are damaged (or destroyed) once per contact.
+func (w *World) detectCollisions() {
+
+	contacts := make(map[planesPair]bool)
+
+	for _, a := range w.sortedPlanes() {
+
+		if a.isDead() {
+			continue
+		}
+
+		for _, entity := range w.grid.withinRadius(a.location, a.hitRadius()) {
+
+			// Each pair is checked once. The ground targets can't collide
+			b, isPlane := entity.(*Plane)
+			if !isPlane || b.UID <= a.UID || b.isDead() {
+				continue
+			}
+
+			pair := planesPair{a.UID, b.UID}
+			contacts[pair] = true
+
+			// They were already touching during the previous tick
+			if w.contacts[pair] {
+				continue
+			}
+
+			w.collide(a, b)
+		}
+	}
+	w.contacts = contacts
+}
+
+// collide damages both planes and tells the players about the collision
+func (w *World) collide(a *Plane, b *Plane) {
+
+	if w.rules.CollisionDamage == 0 {
+		a.destroy(PlaneCollided)
+		b.destroy(PlaneCollided)
+	} else {
+		a.takeDamage(w.rules.CollisionDamage, PlaneCollided)
+		b.takeDamage(w.rules.CollisionDamage, PlaneCollided)
+	}
+
+	// The impact is halfway between the planes
+	impact := a.location.Add(b.location)
+	w.emit(collisionMessage(a.UID, b.UID, impact.MulScalar(0.5)))
+}
+
+// collisionMessage tells the players that two planes collided:
+// 0x8|uid of the first plane|uid of the second plane|impact's location (float32 * 3)
+func collisionMessage(a uint8, b uint8, location mathutils.Vector3D) []byte {
+
+	message := make([]byte, 1+1+1+3*4)
+	message[0] = 0x8 // Collision
+	message[1] = a
+	message[2] = b
+	putVector3D(message[3:], location)
+	return message
+}
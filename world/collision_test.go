@@ -0,0 +1,55 @@
+package world
+
+import (
+	"testing"
+
+	"github.com/eaglesight/eaglesight-server/mathutils"
+)
+
+func TestDetectCollisions(t *testing.T) {
+	w := getTestWorld()
+	w.rules.CollisionDamage = 30
+
+	for uid := uint8(1); uid <= 3; uid++ {
+		w.addPlane(uid, 0, PlaneModel{Life: 100, HitRadius: 10}, w.gun)
+		w.planes[uid].location = mathutils.Vector3D{X: 1000 * float64(uid), Y: 1000, Z: 0}
+	}
+
+	// 1 and 2 touch each other
+	w.planes[2].location.X = w.planes[1].location.X + 15
+
+	w.indexEntities()
+	w.detectCollisions()
+
+	if w.planes[1].life != 70 || w.planes[2].life != 70 || w.planes[3].life != 100 {
+		t.Errorf("Only 1 and 2 should be damaged: %d, %d, %d", w.planes[1].life, w.planes[2].life, w.planes[3].life)
+	}
+
+	collisions := eventsWithOpcode(w, 0x8)
+	if len(collisions) != 1 || collisions[0][1] != 1 || collisions[0][2] != 2 {
+		t.Errorf("The collision should be reported: %v", collisions)
+	}
+
+	// Still in contact: no more damage
+	w.indexEntities()
+	w.detectCollisions()
+
+	if w.planes[1].life != 70 || len(eventsWithOpcode(w, 0x8)) != 1 {
+		t.Error("A contact should only be counted once")
+	}
+}
+
+func TestDeadlyCollision(t *testing.T) {
+	w := getTestWorld()
+
+	w.addPlane(1, 0, PlaneModel{Life: 100}, w.gun)
+	w.addPlane(2, 0, PlaneModel{Life: 100}, w.gun)
+	w.planes[2].location = w.planes[1].location
+
+	w.indexEntities()
+	w.detectCollisions()
+
+	if !w.planes[1].isDead() || !w.planes[2].isDead() || w.planes[1].cause != PlaneCollided {
+		t.Error("Both planes should be destroyed")
+	}
+}
@@ -0,0 +1,190 @@
+package world
+
+import (
+	"encoding/binary"
+
+	"github.com/eaglesight/eaglesight-server/mathutils"
+)
+
+const (
+	// ProjectileFlare is the type of the flares in the snapshots
+	ProjectileFlare = 0x3
+	// ProjectileChaff is the type of the chaff clouds in the snapshots
+	ProjectileChaff = 0x4
+	// countermeasureInterval is the minimum time in seconds between two deployments of a plane
+	countermeasureInterval = 0.5
+	// decoyEffectiveness is the probability that a seeker switches to a decoy it sees. The missile's resistance lowers it
+	decoyEffectiveness = 0.6
+)
+
+// Seekers of the missiles
+const (
+	// SeekerHeat follows the engines. It's decoyed by the flares
+	SeekerHeat = "heat"
+	// SeekerRadar follows the radar echo. It's decoyed by the chaff
+	SeekerRadar = "radar"
+)
+
+// Decoy is a flare or a chaff cloud, deployed by a plane to fool the missiles
+type Decoy struct {
+	id         uint16
+	kind       uint8 // ProjectileFlare or ProjectileChaff
+	source     uint8 // UID of the plane that deployed it
+	location   mathutils.Vector3D
+	speed      mathutils.Vector3D
+	timeToLive float64
+}
+
+// NewDecoy creates a decoy of "kind" deployed by the plane
+func NewDecoy(kind uint8, plane *Plane) *Decoy {
+
+	// Ejected below the plane
+	ejection := mathutils.Vector3D{X: 0, Y: -20, Z: 0}
+	ejection = ejection.MultiplyByMatrix3(&plane.orientation)
+
+	decoy := &Decoy{
+		kind:     kind,
+		source:   plane.UID,
+		location: plane.location,
+		speed:    plane.speed.Add(ejection),
+	}
+
+	switch kind {
+	case ProjectileFlare:
+		decoy.timeToLive = 3
+	case ProjectileChaff:
+		decoy.timeToLive = 4
+	}
+	return decoy
+}
+
+// Update moves the decoy. Returns whether the decoy is still "living"
+func (d *Decoy) Update(deltaT float64, atmosphere *Atmosphere) bool {
+
+	// A chaff cloud stops quickly and drifts with the wind. A flare falls
+	drag := 0.5
+	if d.kind == ProjectileChaff {
+		drag = 3
+	}
+	airspeed := d.speed.Sub(atmosphere.Wind.At(d.location))
+	acceleration := airspeed.MulScalar(-drag)
+	if d.kind == ProjectileFlare {
+		acceleration.Y -= standardGravity
+	}
+
+	d.speed = d.speed.Add(acceleration.MulScalar(deltaT))
+	d.location = d.location.Add(d.speed.MulScalar(deltaT))
+
+	d.timeToLive -= deltaT
+	return d.timeToLive > 0
+}
+
+func (d *Decoy) position() mathutils.Vector3D {
+	return d.location
+}
+
+func (d *Decoy) velocity() mathutils.Vector3D {
+	return d.speed
+}
+
+func (d *Decoy) isDead() bool {
+	return d.timeToLive <= 0
+}
+
+// fools returns whether the decoy can fool the seeker of the missile
+func (d *Decoy) fools(model *MissileModel) bool {
+	if model.Seeker == SeekerRadar {
+		return d.kind == ProjectileChaff
+	}
+	return d.kind == ProjectileFlare
+}
+
+// Read writes the state of the decoy in the snapshot, like a projectile's spawn record
+func (d *Decoy) Read(snapshot []byte) (n int, err error) {
+
+	binary.BigEndian.PutUint16(snapshot[0:], d.id)
+	snapshot[2] = d.kind
+	snapshot[3] = d.source
+	putVector3D(snapshot[4:], d.location)
+	putVector3D(snapshot[16:], d.speed)
+
+	return ProjectileSnapshotSize, nil
+}
+
+// deployCountermeasures drops a flare and a chaff cloud (if there are some left) for every plane that asks for it
+func (w *World) deployCountermeasures(deltaT float64) {
+
+	for _, plane := range w.sortedPlanes() {
+
+		plane.countermeasureCooldown -= deltaT
+
+		if !plane.input.IsDeploying || plane.isDead() || plane.countermeasureCooldown > 0 {
+			continue
+		}
+
+		if plane.flares > 0 {
+			plane.flares--
+			w.addDecoy(NewDecoy(ProjectileFlare, plane))
+		}
+
+		if plane.chaff > 0 {
+			plane.chaff--
+			w.addDecoy(NewDecoy(ProjectileChaff, plane))
+		}
+		plane.countermeasureCooldown = countermeasureInterval
+	}
+}
+
+// addDecoy adds the decoy in the world. It shares the ids of the bullets
+func (w *World) addDecoy(decoy *Decoy) {
+
+	decoy.id = w.nextProjectileID
+	w.nextProjectileID++
+
+	w.decoys = append(w.decoys, decoy)
+}
+
+// updateDecoys moves the decoys and removes the ones that burnt out
+func (w *World) updateDecoys(deltaT float64) {
+
+	decoysStillAlive := []*Decoy{}
+
+	for _, decoy := range w.decoys {
+
+		if decoy.Update(deltaT, w.atmosphere) {
+			decoysStillAlive = append(decoysStillAlive, decoy)
+		} else {
+			w.despawned = append(w.despawned, decoy.id)
+		}
+	}
+	w.decoys = decoysStillAlive
+}
+
+// distractMissiles gives every guided missile one chance to be fooled by each decoy that enters its seeker
+func (w *World) distractMissiles() {
+
+	for _, missile := range w.missiles {
+
+		// A blind missile doesn't look for anything
+		if missile.target == nil {
+			continue
+		}
+
+		for _, decoy := range w.decoys {
+
+			if missile.seen[decoy.id] || !decoy.fools(&missile.model) {
+				continue
+			}
+
+			lineOfSight := decoy.location.Sub(missile.location)
+			if angleBetween(missile.speed, lineOfSight) > missile.model.SeekerCone {
+				continue
+			}
+			missile.seen[decoy.id] = true
+
+			if w.random.Float64() < decoyEffectiveness*(1-missile.model.DecoyResistance) {
+				missile.target = decoy
+			}
+		}
+	}
+}
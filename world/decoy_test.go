@@ -0,0 +1,145 @@
+package world
+
+import (
+	"testing"
+
+	"github.com/eaglesight/eaglesight-server/mathutils"
+)
+
+// decoyedMissile returns a world with a heat seeking missile chasing the plane 2, and a flare right in its seeker
+func decoyedMissile(resistance float64) (w *World, missile *Missile, flare *Decoy) {
+	w, _, target := missileWorld()
+
+	model := dummyMissileModel()
+	model.DecoyResistance = resistance
+
+	missile = NewMissile(1, model, mathutils.Vector3D{X: 2100, Y: 1500, Z: 2500}, mathutils.Vector3D{X: 0, Y: 0, Z: 600}, target)
+	w.addMissile(missile)
+
+	flare = NewDecoy(ProjectileFlare, target)
+	w.addDecoy(flare)
+	return w, missile, flare
+}
+
+func TestDeployCountermeasures(t *testing.T) {
+	w := getTestWorld()
+	w.addPlane(1, 0, PlaneModel{Life: 100, Flares: 2, Chaff: 1}, w.gun)
+	plane := w.planes[1]
+
+	plane.Write([]byte{0x3, 0, 0, 0, 0, 0x20})
+	if !plane.input.IsDeploying || plane.input.IsFiring || plane.input.IsLaunching {
+		t.Fatalf("Wrong input: %+v", plane.input)
+	}
+
+	w.deployCountermeasures(0.01)
+	// Too soon
+	w.deployCountermeasures(0.01)
+
+	if len(w.decoys) != 2 || plane.flares != 1 || plane.chaff != 0 {
+		t.Errorf("A flare and a chaff cloud should be deployed: %d decoys", len(w.decoys))
+	}
+
+	w.deployCountermeasures(1)
+	w.deployCountermeasures(1)
+
+	if len(w.decoys) != 3 || plane.flares != 0 {
+		t.Errorf("There are only 3 countermeasures: %d decoys", len(w.decoys))
+	}
+}
+
+func TestDecoyBurnsOut(t *testing.T) {
+	w, missile, flare := decoyedMissile(0)
+	missile.target = flare
+
+	for i := 0; i < 400; i++ {
+		w.updateDecoys(0.01)
+	}
+
+	if len(w.decoys) != 0 || len(w.despawned) != 1 {
+		t.Fatal("The flare should have burnt out")
+	}
+
+	missile.guidance()
+	if missile.target != nil {
+		t.Error("The missile should fly blind once the flare is gone")
+	}
+}
+
+func TestSeekerIgnoresWrongDecoy(t *testing.T) {
+	w, missile, flare := decoyedMissile(0)
+
+	// A radar doesn't see the flares
+	missile.model.Seeker = SeekerRadar
+	w.distractMissiles()
+
+	if missile.target == flare {
+		t.Error("A radar seeker can't be fooled by a flare")
+	}
+
+	// But it sees the chaff
+	chaff := NewDecoy(ProjectileChaff, w.planes[2])
+	w.addDecoy(chaff)
+	for i := 0; i < 20 && missile.target != chaff; i++ {
+		delete(missile.seen, chaff.id)
+		w.distractMissiles()
+	}
+
+	if missile.target != chaff {
+		t.Error("A radar seeker should be fooled by the chaff")
+	}
+}
+
+func TestSeekerOutOfCone(t *testing.T) {
+	w, missile, flare := decoyedMissile(0)
+
+	// Behind the missile
+	flare.location.Z = 1000
+	w.distractMissiles()
+
+	if missile.target == flare || missile.seen[flare.id] {
+		t.Error("The seeker can't see a flare behind it")
+	}
+}
+
+func TestSeekerSeesDecoyOnce(t *testing.T) {
+	w, missile, flare := decoyedMissile(0)
+	target := missile.target
+
+	for i := 0; i < 20; i++ {
+		missile.target = target
+		w.distractMissiles()
+
+		if i > 0 && missile.target == flare {
+			t.Fatal("The seeker should roll the dice only once per decoy")
+		}
+	}
+}
+
+func TestDecoyProbability(t *testing.T) {
+
+	probability := func(resistance float64) float64 {
+		fooled := 0
+		for i := 0; i < 1000; i++ {
+			w, missile, flare := decoyedMissile(resistance)
+			w.random.Seed(int64(i))
+			w.distractMissiles()
+
+			if missile.target == flare {
+				fooled++
+			}
+		}
+		return float64(fooled) / 1000
+	}
+
+	if p := probability(0); !isClose(p, decoyEffectiveness, 0.05) {
+		t.Errorf("The flare should work %f of the time, not %f", decoyEffectiveness, p)
+	}
+
+	if p := probability(0.5); !isClose(p, decoyEffectiveness/2, 0.05) {
+		t.Errorf("The resistance should halve the chances: %f", p)
+	}
+
+	if p := probability(1); p != 0 {
+		t.Errorf("A fully resistant seeker should never be fooled: %f", p)
+	}
+}
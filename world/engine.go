@@ -0,0 +1,107 @@
+package world
+
+import (
+	"encoding/binary"
+	"math"
+)
+
+// defaultAfterburnerThreshold is used when the model has an afterburner but no threshold
+const defaultAfterburnerThreshold = 0.9
+
+// Engine is the state of the engine and the fuel tank of a plane
+type Engine struct {
+	fuel        float64 // kg
+	power       float64 // 0 (idle) to 1 (full dry power). Follows the throttle with some lag
+	afterburner bool
+}
+
+// throttleTarget converts the throttle input into the power the engine tries to reach.
+// When the plane has an afterburner, the top of the throttle lights it.
+func (p *Plane) throttleTarget() (power float64, afterburner bool) {
+
+	if p.model.AfterburnerThrust <= 0 {
+		return p.input.Thrust, false
+	}
+
+	threshold := p.model.AfterburnerThreshold
+	if threshold <= 0 || threshold >= 1 {
+		threshold = defaultAfterburnerThreshold
+	}
+
+	if p.input.Thrust > threshold {
+		return 1, true
+	}
+	return p.input.Thrust / threshold, false
+}
+
+// updateEngine spools the engine up or down and burns the fuel
+func (p *Plane) updateEngine(deltaT float64) {
+
+	target, afterburner := p.throttleTarget()
+
+	if p.isOutOfFuel() {
+		target = 0
+		afterburner = false
+	}
+
+	// Spool up or down toward the target
+	if p.model.SpoolTime <= 0 {
+		p.engine.power = target
+	} else if step := deltaT / p.model.SpoolTime; p.engine.power < target {
+		p.engine.power = math.Min(target, p.engine.power+step)
+	} else {
+		p.engine.power = math.Max(target, p.engine.power-step)
+	}
+	p.engine.afterburner = afterburner
+
+	// A model without fuel capacity never runs dry
+	if p.model.FuelCapacity <= 0 {
+		return
+	}
+
+	burn := p.model.FuelBurnRate * p.engine.power * deltaT
+	if p.engine.afterburner {
+		burn += p.model.AfterburnerBurnRate * deltaT
+	}
+	p.engine.fuel = math.Max(0, p.engine.fuel-burn)
+}
+
+// isOutOfFuel returns whether the tank is empty
+func (p *Plane) isOutOfFuel() bool {
+	return p.model.FuelCapacity > 0 && p.engine.fuel <= 0
+}
+
+// mass returns the mass of the plane with the fuel it carries
+func (p *Plane) mass() float64 {
+	return p.model.Mass + p.engine.fuel
+}
+
+// telemetryMessage gives the pilot the state of the plane:
+// 0x7|fuel in kg (float32)|fuel in %|engine power in %|afterburner|ammo (uint16)
+func (p *Plane) telemetryMessage() []byte {
+
+	message := make([]byte, 1+4+1+1+1+2)
+	message[0] = 0x7 // Telemetry
+
+	binary.BigEndian.PutUint32(message[1:], math.Float32bits(float32(p.engine.fuel)))
+
+	if p.model.FuelCapacity > 0 {
+		message[5] = uint8(100 * p.engine.fuel / p.model.FuelCapacity)
+	} else {
+		message[5] = 100
+	}
+
+	message[6] = uint8(100 * p.engine.power)
+
+	if p.engine.afterburner {
+		message[7] = 1
+	}
+
+	ammo := 0
+	for _, gun := range p.guns {
+		ammo += int(gun.ammo)
+	}
+	binary.BigEndian.PutUint16(message[8:], uint16(ammo))
+
+	return message
+}
@@ -0,0 +1,92 @@
+package world
+
+import (
+	"encoding/binary"
+	"testing"
+)
+
+func TestSpoolUp(t *testing.T) {
+	plane, _ := dummyPlane(3)
+	plane.model.SpoolTime = 2
+	plane.input.Thrust = 1
+
+	plane.updateEngine(1)
+
+	if !isClose(plane.engine.power, 0.5, 1e-9) {
+		t.Errorf("The engine should be at half power, not %f", plane.engine.power)
+	}
+
+	plane.updateEngine(2)
+
+	if plane.engine.power != 1 {
+		t.Errorf("The engine should be at full power, not %f", plane.engine.power)
+	}
+}
+
+func TestFuelBurn(t *testing.T) {
+	plane, _ := dummyPlane(3)
+	plane.model.FuelCapacity = 100
+	plane.model.FuelBurnRate = 10
+	plane.engine.fuel = 100
+	plane.input.Thrust = 1
+
+	emptyMass := plane.mass() - 100
+
+	plane.updateEngine(5)
+
+	if plane.engine.fuel != 50 || plane.mass() != emptyMass+50 {
+		t.Errorf("Half of the fuel should be burnt: %f", plane.engine.fuel)
+	}
+
+	plane.updateEngine(10)
+
+	if !plane.isOutOfFuel() || plane.engine.fuel != 0 {
+		t.Errorf("The tank should be empty: %f", plane.engine.fuel)
+	}
+
+	plane.updateEngine(0.1)
+
+	if plane.calculateThrust(SeaLevelDensity) != 0 {
+		t.Error("There should be no thrust without fuel")
+	}
+}
+
+func TestAfterburner(t *testing.T) {
+	plane, _ := dummyPlane(3)
+	plane.model.AfterburnerThrust = 20000
+	plane.model.AfterburnerThreshold = 0.8
+
+	plane.input.Thrust = 0.4
+	if power, afterburner := plane.throttleTarget(); power != 0.5 || afterburner {
+		t.Errorf("The engine should target half dry power: %f %t", power, afterburner)
+	}
+
+	plane.input.Thrust = 0.9
+	plane.updateEngine(1)
+
+	if !plane.engine.afterburner || plane.engine.power != 1 {
+		t.Error("The afterburner should be lit")
+	}
+
+	dry := plane.model.MaxThrust / plane.mass()
+	if thrust := plane.calculateThrust(SeaLevelDensity); thrust <= dry {
+		t.Errorf("The afterburner should add thrust: %f / %f", thrust, dry)
+	}
+}
+
+func TestTelemetryMessage(t *testing.T) {
+	plane, _ := dummyPlane(3)
+	plane.model.FuelCapacity = 200
+	plane.engine.fuel = 50
+	plane.engine.power = 0.5
+
+	message := plane.telemetryMessage()
+
+	if message[0] != 0x7 || message[5] != 25 || message[6] != 50 || message[7] != 0 {
+		t.Errorf("Wrong telemetry: %v", message)
+	}
+
+	if binary.BigEndian.Uint16(message[8:]) != dummyGunModel().Ammo {
+		t.Errorf("Wrong ammo: %v", message[8:])
+	}
+}
@@ -0,0 +1,33 @@
+package world
+
+// Event is a message from the world to the players.
+// It's sent to everybody unless it's private.
+type Event struct {
+	Data      []byte
+	Private   bool
+	Recipient uint8 // UID of the only player that receives a private event
+}
+
+// States of a plane reported in the plane state messages
+const (
+	// PlaneDestroyed : the plane was shot down
+	PlaneDestroyed = 0x1
+	// PlaneCrashed : the plane hit the ground too hard
+	PlaneCrashed = 0x2
+	// PlaneRespawned : the plane is back in the world
+	PlaneRespawned = 0x3
+	// PlaneCollided : the plane collided with another plane
+	PlaneCollided = 0x4
+	// PlaneOutOfBounds : the plane stayed out of the battle area for too long
+	PlaneOutOfBounds = 0x5
+)
+
+// planeStateMessage tells the players that the plane "uid" changed state
+func planeStateMessage(uid uint8, state uint8) []byte {
+	// 0x5 + plane's uid + state
+	message := make([]byte, 3)
+	message[0] = 0x5 // Plane state
+	message[1] = uid
+	message[2] = state
+	return message
+}
@@ -0,0 +1,124 @@
+package world
+
+import (
+	"math"
+
+	"github.com/eaglesight/eaglesight-server/mathutils"
+)
+
+// gridCellSize is the approximate width (X and Z) of a cell of the spatial grid
+const gridCellSize = 100
+
+// hittable is an entity of the world that can be hit
+type hittable interface {
+	position() mathutils.Vector3D
+	hitRadius() float64
+}
+
+// damageable is an entity that the projectiles can damage: a plane or a ground target
+type damageable interface {
+	hittable
+	isHitBy(b *Bullet) (t float64, hit bool)
+	takeDamage(damage uint8, cause uint8)
+	isDead() bool
+}
+
+// gridCell identifies a column of the spatial grid
+type gridCell struct {
+	col, row int
+}
+
+// spatialGrid is a uniform grid over X and Z that indexes the entities of the world.
+// It's rebuilt every tick.
+type spatialGrid struct {
+	cellSize  float64
+	cells     map[gridCell][]hittable
+	maxRadius float64 // Biggest hit radius in the grid. The queries are extended by it
+}
+
+// newSpatialGrid returns an empty grid whose cells are aligned with the squares of the terrain
+func newSpatialGrid(terrain *Terrain) *spatialGrid {
+
+	cellSize := float64(gridCellSize)
+
+	if terrain != nil && terrain.distance > 0 {
+		cellSize = math.Ceil(gridCellSize/terrain.distance) * terrain.distance
+	}
+
+	return &spatialGrid{
+		cellSize: cellSize,
+		cells:    make(map[gridCell][]hittable),
+	}
+}
+
+// clear empties the grid
+func (g *spatialGrid) clear() {
+	g.cells = make(map[gridCell][]hittable)
+	g.maxRadius = 0
+}
+
+// cellOf returns the cell that contains the location
+func (g *spatialGrid) cellOf(location mathutils.Vector3D) gridCell {
+	return gridCell{
+		col: int(math.Floor(location.X / g.cellSize)),
+		row: int(math.Floor(location.Z / g.cellSize)),
+	}
+}
+
+// insert adds the entity in the cell that contains its center
+func (g *spatialGrid) insert(entity hittable) {
+
+	cell := g.cellOf(entity.position())
+	g.cells[cell] = append(g.cells[cell], entity)
+
+	if entity.hitRadius() > g.maxRadius {
+		g.maxRadius = entity.hitRadius()
+	}
+}
+
+// inBox calls "visit" for every entity whose cell overlaps the box [min, max] (on X and Z).
+// The cells are always visited in the same order.
+func (g *spatialGrid) inBox(min mathutils.Vector3D, max mathutils.Vector3D, visit func(hittable)) {
+
+	from := g.cellOf(min)
+	to := g.cellOf(max)
+
+	for col := from.col; col <= to.col; col++ {
+		for row := from.row; row <= to.row; row++ {
+			for _, entity := range g.cells[gridCell{col: col, row: row}] {
+				visit(entity)
+			}
+		}
+	}
+}
+
+// withinRadius returns the entities whose hit sphere intersects the sphere (center, radius)
+func (g *spatialGrid) withinRadius(center mathutils.Vector3D, radius float64) (entities []hittable) {
+
+	reach := radius + g.maxRadius
+	extent := mathutils.Vector3D{X: reach, Y: 0, Z: reach}
+
+	g.inBox(center.Sub(extent), center.Add(extent), func(entity hittable) {
+		if mathutils.Distance(center, entity.position()) <= radius+entity.hitRadius() {
+			entities = append(entities, entity)
+		}
+	})
+	return entities
+}
+
+// alongSegment returns the entities whose hit sphere is closer than "radius" to the segment [a, b]
+func (g *spatialGrid) alongSegment(a mathutils.Vector3D, b mathutils.Vector3D, radius float64) (entities []hittable) {
+
+	reach := radius + g.maxRadius
+	min := mathutils.Vector3D{X: math.Min(a.X, b.X) - reach, Y: 0, Z: math.Min(a.Z, b.Z) - reach}
+	max := mathutils.Vector3D{X: math.Max(a.X, b.X) + reach, Y: 0, Z: math.Max(a.Z, b.Z) + reach}
+
+	g.inBox(min, max, func(entity hittable) {
+		closest := mathutils.ClosestPointOnSegment(entity.position(), a, b)
+
+		if mathutils.Distance(closest, entity.position()) <= radius+entity.hitRadius() {
+			entities = append(entities, entity)
+		}
+	})
+	return entities
+}
@@ -0,0 +1,153 @@
+package world
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+
+	"github.com/eaglesight/eaglesight-server/mathutils"
+)
+
+// dummyEntity is a sphere in the grid
+type dummyEntity struct {
+	location mathutils.Vector3D
+	radius   float64
+}
+
+func (e *dummyEntity) position() mathutils.Vector3D { return e.location }
+func (e *dummyEntity) hitRadius() float64           { return e.radius }
+
+func TestGridCellSize(t *testing.T) {
+	w := getTestWorld()
+
+	if int(w.grid.cellSize)%int(w.terrain.distance) != 0 || w.grid.cellSize < gridCellSize {
+		t.Errorf("The cells (%f) should be aligned with the terrain (%f)", w.grid.cellSize, w.terrain.distance)
+	}
+
+	if newSpatialGrid(nil).cellSize != gridCellSize {
+		t.Fail()
+	}
+}
+
+func TestWithinRadius(t *testing.T) {
+	grid := newSpatialGrid(nil)
+
+	near := &dummyEntity{location: mathutils.Vector3D{X: 95, Y: 0, Z: 0}, radius: 10}
+	far := &dummyEntity{location: mathutils.Vector3D{X: 500, Y: 0, Z: 0}, radius: 10}
+	below := &dummyEntity{location: mathutils.Vector3D{X: 0, Y: -300, Z: 0}, radius: 10}
+	negative := &dummyEntity{location: mathutils.Vector3D{X: -105, Y: 0, Z: -5}, radius: 30}
+
+	for _, e := range []hittable{near, far, below, negative} {
+		grid.insert(e)
+	}
+
+	// "near" is in the next cell but its sphere reaches the query
+	entities := grid.withinRadius(mathutils.Vector3D{X: 0, Y: 0, Z: 0}, 90)
+
+	if len(entities) != 2 || entities[0] != negative || entities[1] != near {
+		t.Errorf("Only 'negative' and 'near' should be found: %v", entities)
+	}
+
+	grid.clear()
+
+	if len(grid.withinRadius(mathutils.Vector3D{X: 95, Y: 0, Z: 0}, 100)) != 0 {
+		t.Error("The grid should be empty")
+	}
+}
+
+func TestAlongSegment(t *testing.T) {
+	grid := newSpatialGrid(nil)
+
+	onPath := &dummyEntity{location: mathutils.Vector3D{X: 250, Y: 5, Z: 250}, radius: 8}
+	aside := &dummyEntity{location: mathutils.Vector3D{X: 250, Y: 5, Z: 300}, radius: 8}
+
+	grid.insert(onPath)
+	grid.insert(aside)
+
+	entities := grid.alongSegment(mathutils.Vector3D{X: 0, Y: 0, Z: 0}, mathutils.Vector3D{X: 500, Y: 0, Z: 500}, 0)
+
+	if len(entities) != 1 || entities[0] != onPath {
+		t.Errorf("Only 'onPath' should be along the segment: %v", entities)
+	}
+}
+
+// spreadPlanes adds "count" planes to the world, 300 units apart
+func spreadPlanes(w *World, count int, model PlaneModel) {
+	for i := 0; i < count; i++ {
+		uid := uint8(i + 1)
+		w.addPlane(uid, 0, model, w.gun)
+		w.planes[uid].location = mathutils.Vector3D{X: float64(i%8) * 300, Y: 1500, Z: float64(i/8) * 300}
+	}
+}
+
+// randomBullets returns bullets scattered among the planes placed by spreadPlanes
+func randomBullets(count int) []*Bullet {
+	random := rand.New(rand.NewSource(1))
+	bullets := make([]*Bullet, count)
+
+	for i := range bullets {
+		location := mathutils.Vector3D{X: random.Float64() * 2400, Y: 1500 + random.Float64()*20, Z: random.Float64() * 2400}
+		bullets[i] = &Bullet{
+			source:   0,
+			location: location,
+			previous: location,
+		}
+	}
+	return bullets
+}
+
+func BenchmarkHitDetection(b *testing.B) {
+
+	for _, count := range []int{100, 500, 2000} {
+
+		w := getTestWorld()
+		spreadPlanes(w, 64, PlaneModel{Life: 255})
+		w.indexEntities()
+		bullets := randomBullets(count)
+
+		b.Run(fmt.Sprintf("grid/%d", count), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				for _, bullet := range bullets {
+					for _, entity := range w.grid.withinRadius(bullet.location, 0) {
+						entity.(*Plane).isHitBy(bullet)
+					}
+				}
+			}
+		})
+
+		b.Run(fmt.Sprintf("naive/%d", count), func(b *testing.B) {
+			planes := w.sortedPlanes()
+
+			for i := 0; i < b.N; i++ {
+				for _, bullet := range bullets {
+					for _, plane := range planes {
+						plane.isHitBy(bullet)
+					}
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkTickWithBullets(b *testing.B) {
+
+	for _, count := range []int{100, 500, 2000} {
+
+		b.Run(fmt.Sprintf("%d", count), func(b *testing.B) {
+			w := getTestWorld()
+			spreadPlanes(w, 64, PlaneModel{Life: 255, Mass: 4000})
+			// Keep the planes still so the population of bullets stays the same
+			for _, plane := range w.planes {
+				plane.speed = mathutils.Vector3D{}
+			}
+			bullets := randomBullets(count)
+
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				w.bullets = append(w.bullets[:0], bullets...)
+				w.spawned = []*Bullet{}
+				w.updateWorld(defaultTimeStep)
+			}
+		})
+	}
+}
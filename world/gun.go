@@ -0,0 +1,86 @@
+package world
+
+// GunModel are the constant properties of a gun. They are part of a PlaneModel
+type GunModel struct {
+	Name        string  `json:"name"`
+	MuzzleSpeed float64 `json:"muzzleSpeed"` // unit / seconds
+	Damage      uint8   `json:"damage"`      // Damage made by each bullet
+	RateOfFire  float64 `json:"rateOfFire"`  // Rounds / seconds
+	Ammo        uint16  `json:"ammo"`        // Rounds available at spawn
+	HeatPerShot float64 `json:"heatPerShot"` // Heat added by each round. The gun overheats at 1. 0 means no overheating
+	CoolingRate float64 `json:"coolingRate"` // Heat removed every second
+	Dispersion  float64 `json:"dispersion"`  // Standard deviation of the angle between a bullet and the axis of the gun (radians)
+	Lifetime    float64 `json:"lifetime"`    // Seconds before a bullet disappears. 0 means defaultBulletLifetime
+}
+
+// Gun is the state of a gun mounted on a plane
+type Gun struct {
+	model      GunModel
+	ammo       uint16
+	cooldown   float64 // Seconds before the next round can be fired
+	heat       float64
+	overheated bool
+}
+
+// NewGun returns a loaded gun
+func NewGun(model GunModel) *Gun {
+	return &Gun{
+		model:      model,
+		ammo:       model.Ammo,
+		cooldown:   0,
+		heat:       0,
+		overheated: false,
+	}
+}
+
+// update updates the state of the gun and returns how many rounds were fired during deltaT
+func (g *Gun) update(deltaT float64, isFiring bool) (rounds int) {
+
+	// Cool the gun down
+	g.heat -= g.model.CoolingRate * deltaT
+	if g.heat <= 0 {
+		g.heat = 0
+		// The gun can fire again once it's completely cold
+		g.overheated = false
+	}
+
+	g.cooldown -= deltaT
+
+	if !isFiring || g.model.RateOfFire <= 0 {
+		// Don't stack rounds while the trigger is released
+		if g.cooldown < 0 {
+			g.cooldown = 0
+		}
+		return 0
+	}
+
+	for g.cooldown <= 0 && g.isReady() {
+		rounds++
+		g.ammo--
+		g.cooldown += 1 / g.model.RateOfFire
+		g.heat += g.model.HeatPerShot
+
+		if g.model.HeatPerShot > 0 && g.heat >= 1 {
+			g.overheated = true
+		}
+	}
+
+	// Out of ammo or overheated: the next round will wait for the trigger
+	if g.cooldown < 0 {
+		g.cooldown = 0
+	}
+	return rounds
+}
+
+// bulletLifetime returns how long the bullets of the gun live
+func (g *Gun) bulletLifetime() float64 {
+	if g.model.Lifetime <= 0 {
+		return defaultBulletLifetime
+	}
+	return g.model.Lifetime
+}
+
+// isReady returns whether the gun can fire
+func (g *Gun) isReady() bool {
+	return g.ammo > 0 && !g.overheated
+}
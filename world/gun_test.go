@@ -0,0 +1,71 @@
+package world
+
+import "testing"
+
+func dummyGunModel() GunModel {
+	return GunModel{
+		MuzzleSpeed: 600,
+		Damage:      10,
+		RateOfFire:  10,
+		Ammo:        100,
+	}
+}
+
+func TestGunRateOfFire(t *testing.T) {
+	gun := NewGun(dummyGunModel())
+
+	rounds := 0
+	// 100 updates of 10ms
+	for i := 0; i < 100; i++ {
+		rounds += gun.update(0.01, true)
+	}
+
+	if rounds != 10 {
+		t.Errorf("%d rounds were fired instead of 10", rounds)
+	}
+
+	if gun.ammo != 90 {
+		t.Errorf("%d rounds left instead of 90", gun.ammo)
+	}
+
+	// Nothing is fired when the trigger is released
+	if gun.update(1, false) != 0 {
+		t.Fail()
+	}
+}
+
+func TestGunAmmo(t *testing.T) {
+	model := dummyGunModel()
+	model.Ammo = 3
+	gun := NewGun(model)
+
+	if rounds := gun.update(10, true); rounds != 3 {
+		t.Errorf("%d rounds were fired instead of 3", rounds)
+	}
+
+	if gun.isReady() || gun.update(10, true) != 0 {
+		t.Error("The gun should be empty")
+	}
+}
+
+func TestGunOverheat(t *testing.T) {
+	model := dummyGunModel()
+	model.HeatPerShot = 0.25
+	model.CoolingRate = 0.5
+	gun := NewGun(model)
+
+	if rounds := gun.update(1, true); rounds != 4 {
+		t.Errorf("%d rounds were fired instead of 4", rounds)
+	}
+
+	if !gun.overheated || gun.update(1, true) != 0 {
+		t.Error("The gun should be overheated")
+	}
+
+	// Cool down completely
+	gun.update(2, false)
+
+	if !gun.isReady() {
+		t.Errorf("The gun should have cooled down (heat: %f)", gun.heat)
+	}
+}
@@ -0,0 +1,232 @@
+package world
+
+import (
+	"encoding/binary"
+	"math"
+	"sort"
+)
+
+// Phases of a match
+const (
+	// MatchWaiting : not enough players yet
+	MatchWaiting = 0x0
+	// MatchCountdown : the match is about to start
+	MatchCountdown = 0x1
+	// MatchLive : the players fight
+	MatchLive = 0x2
+	// MatchOvertime : the leaders were tied at the time limit. The first to break the tie wins
+	MatchOvertime = 0x3
+	// MatchEnded : the match is over
+	MatchEnded = 0x4
+)
+
+// NoWinner is the winner of a draw. It's reserved: no player can have this UID
+const NoWinner = 0xFF
+
+// MatchRules describe how a match goes
+type MatchRules struct {
+	MinPlayers int     `json:"minPlayers"` // Players needed to start the countdown
+	Countdown  float64 `json:"countdown"`  // Seconds before the match starts once there are enough players
+	TimeLimit  float64 `json:"timeLimit"`  // Seconds of play. 0 means no limit
+	ScoreLimit int     `json:"scoreLimit"` // Points needed to win. 0 means no limit
+	Overtime   float64 `json:"overtime"`   // Seconds of sudden death when the leaders are tied at the time limit. 0 means a draw
+	EndDelay   float64 `json:"endDelay"`   // Seconds before the next match, if Restart
+	Restart    bool    `json:"restart"`    // Start a new match at the end instead of stopping
+}
+
+// MatchResults are sent by the world at the end of every match
+type MatchResults struct {
+	Winner     uint8 // uid of the winner, or its team in a team match. NoWinner if it's a draw
+	Scores     map[uint8]Score
+	TeamScores map[uint8]int
+}
+
+// playing returns whether the players can play (the inputs are frozen otherwise)
+func (w *World) playing() bool {
+	return w.phase == MatchLive || w.phase == MatchOvertime
+}
+
+// updateMatch moves the match to its next phase when it's time. Phases without a duration
+// are passed in the same tick, but a match that ends or leaves the end waits for the next one
+func (w *World) updateMatch(deltaT float64) {
+
+	w.phaseTimeLeft -= deltaT
+
+	if w.playing() {
+		w.mode.Tick(w, deltaT)
+	}
+
+	for phase := w.phase; ; phase = w.phase {
+		w.updatePhase()
+
+		if w.phase == phase || w.phase == MatchEnded || phase == MatchEnded {
+			return
+		}
+	}
+}
+
+// updatePhase checks the condition to leave the current phase
+func (w *World) updatePhase() {
+
+	rules := w.rules.Match
+
+	switch w.phase {
+	case MatchWaiting:
+		if len(w.planes) >= rules.MinPlayers {
+			w.setPhase(MatchCountdown, rules.Countdown)
+		}
+	case MatchCountdown:
+		if len(w.planes) < rules.MinPlayers {
+			w.setPhase(MatchWaiting, 0)
+		} else if w.phaseTimeLeft <= 0 {
+			w.startMatch()
+		}
+	case MatchLive, MatchOvertime:
+		w.checkWin()
+	case MatchEnded:
+		if rules.Restart && w.phaseTimeLeft <= 0 {
+			w.setPhase(MatchWaiting, 0)
+		}
+	}
+}
+
+// setPhase changes the phase of the match and tells the players
+func (w *World) setPhase(phase uint8, duration float64) {
+
+	w.phase = phase
+	w.phaseTimeLeft = duration
+	w.emit(phaseMessage(w.phase, w.phaseTimeLeft, w.winner))
+}
+
+// startMatch clears the scores and the projectiles, rebuilds the targets and puts every plane on a spawn point
+func (w *World) startMatch() {
+
+	w.scoreboard.reset()
+	w.winner = NoWinner
+	w.clearProjectiles()
+
+	w.placeTargets()
+	if len(w.targets) > 0 {
+		w.emit(targetsMessage(w.targets))
+	}
+
+	for _, plane := range w.sortedPlanes() {
+		w.scoreboard.join(plane.UID, plane.team)
+		delete(w.respawns, plane.UID)
+		plane.respawn(w.pickSpawnPoint(plane.team))
+		w.emit(planeStateMessage(plane.UID, PlaneRespawned))
+	}
+	w.mode.Start(w)
+	w.setPhase(MatchLive, w.rules.Match.TimeLimit)
+}
+
+// clearProjectiles removes everything that is still flying from the previous match
+func (w *World) clearProjectiles() {
+
+	w.collectBullets()
+	for _, bullet := range w.bullets {
+		w.despawnBullet(bullet)
+	}
+	for _, missile := range w.missiles {
+		w.despawned = append(w.despawned, missile.id)
+	}
+	for _, decoy := range w.decoys {
+		w.despawned = append(w.despawned, decoy.id)
+	}
+	for _, bomb := range w.bombs {
+		w.despawned = append(w.despawned, bomb.id)
+	}
+
+	w.bullets = []*Bullet{}
+	w.missiles = []*Missile{}
+	w.decoys = []*Decoy{}
+	w.bombs = []*Bomb{}
+}
+
+// checkWin ends the match when the game mode says so, the score limit is reached, or the time is up
+func (w *World) checkWin() {
+
+	rules := w.rules.Match
+	winner, points, tied := leader(w.mode.Standings(w))
+
+	if over, modeWinner := w.mode.IsOver(w); over {
+		w.endMatch(modeWinner)
+		return
+	}
+
+	switch {
+	case rules.ScoreLimit > 0 && points >= rules.ScoreLimit && !tied:
+		w.endMatch(winner)
+	case w.phase == MatchOvertime && !tied:
+		w.endMatch(winner)
+	case w.phase == MatchOvertime && w.phaseTimeLeft <= 0:
+		w.endMatch(NoWinner)
+	case w.phase == MatchLive && rules.TimeLimit > 0 && w.phaseTimeLeft <= 0:
+		if !tied {
+			w.endMatch(winner)
+		} else if rules.Overtime > 0 {
+			w.setPhase(MatchOvertime, rules.Overtime)
+		} else {
+			w.endMatch(NoWinner)
+		}
+	}
+}
+
+// leader returns who has the most points in the standings
+func leader(standings map[uint8]int) (leader uint8, points int, tied bool) {
+
+	keys := make([]int, 0, len(standings))
+	for key := range standings {
+		keys = append(keys, int(key))
+	}
+	sort.Ints(keys)
+
+	leader, points, tied = NoWinner, math.MinInt32, true
+	for _, key := range keys {
+		switch score := standings[uint8(key)]; {
+		case score > points:
+			leader, points, tied = uint8(key), score, false
+		case score == points:
+			tied = true
+		}
+	}
+	return leader, points, tied
+}
+
+// endMatch freezes the planes and sends the results
+func (w *World) endMatch(winner uint8) {
+
+	w.winner = winner
+	for _, plane := range w.planes {
+		plane.input = PlaneInput{}
+	}
+	w.setPhase(MatchEnded, w.rules.Match.EndDelay)
+
+	select {
+	case w.Over <- w.Results():
+	default:
+		// Nobody looks at the results
+	}
+}
+
+// Results returns the results of the match so far. Only call it from another goroutine when the world is over
+func (w *World) Results() MatchResults {
+	return MatchResults{
+		Winner:     w.winner,
+		Scores:     w.scoreboard.Scores(),
+		TeamScores: w.scoreboard.TeamScores(),
+	}
+}
+
+// phaseMessage tells the players about the match:
+// 0x10|phase|time left in the phase (float32, 0 when there is no limit)|winner
+func phaseMessage(phase uint8, timeLeft float64, winner uint8) []byte {
+
+	message := make([]byte, 1+1+4+1)
+	message[0] = 0x10
+	message[1] = phase
+	binary.BigEndian.PutUint32(message[2:], math.Float32bits(float32(math.Max(0, timeLeft))))
+	message[6] = winner
+
+	return message
+}
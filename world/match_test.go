@@ -0,0 +1,168 @@
+package world
+
+import (
+	"testing"
+)
+
+// lastPhase returns the last phase message queued
+func lastPhase(w *World) []byte {
+	phases := eventsWithOpcode(w, 0x10)
+	if len(phases) == 0 {
+		return nil
+	}
+	return phases[len(phases)-1]
+}
+
+func TestDefaultMatch(t *testing.T) {
+	w := getTestWorld()
+
+	if w.phase != MatchLive || !w.playing() {
+		t.Errorf("Without match rules, the world should be live right away: phase %d", w.phase)
+	}
+}
+
+func TestMatchStart(t *testing.T) {
+	w := newTestWorld(Scenario{}, Rules{Match: MatchRules{MinPlayers: 2, Countdown: 5}})
+
+	w.addPlane(1, 0, PlaneModel{Life: 100}, w.gun)
+	w.updateMatch(1)
+
+	if w.phase != MatchWaiting {
+		t.Fatalf("The match should wait for the second player: phase %d", w.phase)
+	}
+
+	// The inputs are frozen
+	w.applyInput(&PlayerInput{UID: 1, Data: []byte{0x3, 0, 0, 0, 0, 0x80}})
+	if w.planes[1].input.IsFiring {
+		t.Error("The input should be ignored before the match")
+	}
+
+	w.addPlane(2, 0, PlaneModel{Life: 100}, w.gun)
+	w.updateMatch(1)
+
+	if phase := lastPhase(w); w.phase != MatchCountdown || phase[1] != MatchCountdown {
+		t.Fatalf("The countdown should start: %v", phase)
+	}
+
+	w.updateMatch(4.5)
+	if w.phase != MatchCountdown {
+		t.Fatal("The countdown is not over")
+	}
+
+	w.updateMatch(1)
+	if w.phase != MatchLive || lastPhase(w)[1] != MatchLive {
+		t.Fatalf("The match should be live: phase %d", w.phase)
+	}
+
+	w.applyInput(&PlayerInput{UID: 1, Data: []byte{0x3, 0, 0, 0, 0, 0x80}})
+	if !w.planes[1].input.IsFiring {
+		t.Error("The input should be applied during the match")
+	}
+}
+
+func TestCountdownInterrupted(t *testing.T) {
+	w := newTestWorld(Scenario{}, Rules{Match: MatchRules{MinPlayers: 2, Countdown: 5}})
+	w.addPlane(1, 0, PlaneModel{}, w.gun)
+	w.addPlane(2, 0, PlaneModel{}, w.gun)
+	w.updateMatch(1)
+
+	w.removePlane(2)
+	w.updateMatch(1)
+
+	if w.phase != MatchWaiting {
+		t.Errorf("The match should wait again: phase %d", w.phase)
+	}
+}
+
+func TestScoreLimit(t *testing.T) {
+	w := newTestWorld(Scenario{}, Rules{Match: MatchRules{MinPlayers: 2, ScoreLimit: 1}}, 0, 0)
+
+	w.hurt(1, w.planes[2], 255)
+	w.detectDeaths()
+	w.updateMatch(0.01)
+
+	if w.phase != MatchEnded || w.winner != 1 || lastPhase(w)[6] != 1 {
+		t.Fatalf("The plane 1 should win: phase %d, winner %d", w.phase, w.winner)
+	}
+
+	results := <-w.Over
+	if results.Winner != 1 || results.Scores[1].Kills != 1 {
+		t.Errorf("Wrong results: %+v", results)
+	}
+
+	// Frozen
+	w.applyInput(&PlayerInput{UID: 1, Data: []byte{0x3, 0, 0, 0, 0, 0x80}})
+	if w.planes[1].input.IsFiring {
+		t.Error("The input should be ignored after the match")
+	}
+}
+
+func TestOvertime(t *testing.T) {
+	w := newTestWorld(Scenario{}, Rules{Match: MatchRules{MinPlayers: 2, TimeLimit: 60, Overtime: 30}}, 0, 0)
+
+	w.updateMatch(60)
+	if w.phase != MatchOvertime {
+		t.Fatalf("The leaders are tied, it should be the overtime: phase %d", w.phase)
+	}
+
+	w.hurt(2, w.planes[1], 255)
+	w.detectDeaths()
+	w.updateMatch(0.01)
+
+	if w.phase != MatchEnded || w.winner != 2 {
+		t.Errorf("The first kill should win: phase %d, winner %d", w.phase, w.winner)
+	}
+}
+
+func TestDraw(t *testing.T) {
+	w := newTestWorld(Scenario{}, Rules{Match: MatchRules{MinPlayers: 2, TimeLimit: 60, Overtime: 30}}, 0, 0)
+
+	w.updateMatch(60)
+	w.updateMatch(30)
+
+	if w.phase != MatchEnded || w.winner != NoWinner {
+		t.Errorf("It should be a draw: phase %d, winner %d", w.phase, w.winner)
+	}
+}
+
+func TestRestart(t *testing.T) {
+	w := newTestWorld(Scenario{}, Rules{Match: MatchRules{MinPlayers: 2, Countdown: 5, ScoreLimit: 1, EndDelay: 10, Restart: true}}, 0, 0)
+	w.updateMatch(5)
+
+	w.scenario.Targets = []GroundTargetModel{{Name: "bunker", Kind: "building", Life: 100, HitRadius: 10}}
+	w.placeTargets()
+	w.hurt(1, w.targets[0], 255)
+
+	w.hurt(1, w.planes[2], 255)
+	w.detectDeaths()
+	w.updateMatch(0.01)
+
+	// Still flying from the previous match
+	w.addBullet(NewBullet(1, w.planes[1].location, w.planes[1].speed, 10, 5))
+	w.addMissile(NewMissile(1, dummyMissileModel(), w.planes[1].location, w.planes[1].speed, nil))
+	w.generateSnapshots()
+
+	w.updateMatch(10)
+	if w.phase != MatchWaiting {
+		t.Fatalf("The match should be over: phase %d", w.phase)
+	}
+	w.updateMatch(0)
+
+	// Both players are there, so it goes to the countdown
+	if w.phase != MatchCountdown {
+		t.Fatalf("A new match should start: phase %d", w.phase)
+	}
+
+	w.updateMatch(5)
+	if w.phase != MatchLive || w.Scoreboard().Scores()[1].Kills != 0 || w.planes[2].isDead() {
+		t.Error("The scores and the planes should be reset")
+	}
+
+	if w.targets[0].isDead() || len(eventsWithOpcode(w, 0xD)) != 1 {
+		t.Error("The targets should be rebuilt and sent")
+	}
+
+	if len(w.bullets) != 0 || len(w.missiles) != 0 || len(w.despawned) != 2 {
+		t.Errorf("The projectiles should be removed: %d despawned", len(w.despawned))
+	}
+}
@@ -0,0 +1,369 @@
+package world
+
+import (
+	"encoding/binary"
+	"math"
+
+	"github.com/eaglesight/eaglesight-server/mathutils"
+)
+
+const (
+	// ProjectileMissile is the type of the missiles in the snapshots
+	ProjectileMissile = 0x2
+	// missileLaunchInterval is the minimum time in seconds between two launches of a plane
+	missileLaunchInterval = 1
+	// defaultNavigationConstant is used when the model doesn't set one. Usually between 3 and 5
+	defaultNavigationConstant = 4
+	// missileDrag is the drag of a missile divided by its mass, at sea level
+	missileDrag = 0.0002
+)
+
+// Warnings sent to the player targeted by a seeker
+const (
+	// WarningClear : the seeker is no longer on the player
+	WarningClear = 0x0
+	// WarningLocking : a seeker is trying to lock the player
+	WarningLocking = 0x1
+	// WarningLocked : the seeker locked the player
+	WarningLocked = 0x2
+	// WarningLaunch : a missile was launched at the player
+	WarningLaunch = 0x3
+)
+
+// MissileModel are the constant properties of a missile. They are part of a PlaneModel
+type MissileModel struct {
+	Name               string  `json:"name"`
+	Count              uint8   `json:"count"`              // Missiles carried at spawn
+	SeekerCone         float64 `json:"seekerCone"`         // Half angle of the seeker's cone (radians)
+	SeekerRange        float64 `json:"seekerRange"`        // Maximum distance of a target
+	LockTime           float64 `json:"lockTime"`           // Seconds the target must stay in the cone to be locked
+	Acceleration       float64 `json:"acceleration"`       // unit / seconds^2 while the motor burns
+	BurnTime           float64 `json:"burnTime"`           // Seconds of fuel
+	TurnRate           float64 `json:"turnRate"`           // Maximum turn rate (radians / seconds)
+	NavigationConstant float64 `json:"navigationConstant"` // Gain of the proportional navigation. 0 means defaultNavigationConstant
+	ProximityFuse      float64 `json:"proximityFuse"`      // The missile explodes when a plane is closer than this
+	Damage             uint8   `json:"damage"`
+	Lifetime           float64 `json:"lifetime"`        // Seconds before the missile self-destructs
+	Seeker             string  `json:"seeker"`          // SeekerHeat or SeekerRadar. Heat when it's empty
+	DecoyResistance    float64 `json:"decoyResistance"` // 0 to 1. 1 means the decoys never work
+}
+
+// Launcher is the state of the missiles of a type carried by a plane
+type Launcher struct {
+	model MissileModel
+	count uint8
+}
+
+// Seeker is what the missile seeker of a plane is looking at
+type Seeker struct {
+	target *Plane  // nil when there is nothing in the cone
+	lock   float64 // Seconds the target has been in the cone
+	locked bool
+}
+
+// trackable is what a missile can be guided to: a plane or a decoy
+type trackable interface {
+	position() mathutils.Vector3D
+	velocity() mathutils.Vector3D
+	isDead() bool
+}
+
+// Missile is a guided projectile
+type Missile struct {
+	id       uint16
+	source   uint8
+	model    MissileModel
+	target   trackable // nil when the missile flies blind
+	location mathutils.Vector3D
+	previous mathutils.Vector3D // Location before the last update
+	speed    mathutils.Vector3D
+	age      float64         // Seconds since the launch
+	seen     map[uint16]bool // Decoys the seeker already saw (and ignored or not)
+}
+
+// NewMissile creates a missile at origin, moving at velocity (global space), guided to "target"
+func NewMissile(source uint8, model MissileModel, origin mathutils.Vector3D, velocity mathutils.Vector3D, target trackable) *Missile {
+
+	return &Missile{
+		source:   source,
+		model:    model,
+		target:   target,
+		location: origin,
+		previous: origin,
+		speed:    velocity,
+		seen:     make(map[uint16]bool),
+	}
+}
+
+// Update guides and moves the missile. Returns whether the missile is still "living"
+func (m *Missile) Update(deltaT float64, atmosphere *Atmosphere) bool {
+
+	m.previous = m.location
+	m.age += deltaT
+
+	acceleration := m.guidance()
+
+	// The motor pushes along the missile's axis (its velocity)
+	if m.age <= m.model.BurnTime {
+		if speed := m.speed.Length(); speed > 0 {
+			acceleration = acceleration.Add(m.speed.MulScalar(m.model.Acceleration / speed))
+		}
+	}
+
+	airspeed := m.speed.Sub(atmosphere.Wind.At(m.location))
+	densityRatio := atmosphere.Density(m.location.Y) / SeaLevelDensity
+	acceleration = acceleration.Add(airspeed.MulScalar(-missileDrag * densityRatio * airspeed.Length()))
+	acceleration.Y -= standardGravity
+
+	m.speed = m.speed.Add(acceleration.MulScalar(deltaT))
+	m.location = m.location.Add(m.speed.MulScalar(deltaT))
+
+	return m.age < m.model.Lifetime
+}
+
+// guidance returns the lateral acceleration asked by the proportional navigation:
+// the missile turns N times faster than the line of sight to the target
+func (m *Missile) guidance() (acceleration mathutils.Vector3D) {
+
+	if m.target == nil {
+		return acceleration
+	}
+
+	targetLocation := m.target.position()
+	lineOfSight := targetLocation.Sub(m.location)
+	distanceSquared := mathutils.DotProduct(&lineOfSight, &lineOfSight)
+
+	// The target is lost when it leaves the cone of the seeker
+	if m.target.isDead() || distanceSquared == 0 || angleBetween(m.speed, lineOfSight) > m.model.SeekerCone {
+		m.target = nil
+		return acceleration
+	}
+
+	targetSpeed := m.target.velocity()
+	relativeSpeed := targetSpeed.Sub(m.speed)
+	rotation := mathutils.CrossProduct(&lineOfSight, &relativeSpeed)
+	rotation = rotation.DivScalar(distanceSquared)
+
+	navigationConstant := m.model.NavigationConstant
+	if navigationConstant <= 0 {
+		navigationConstant = defaultNavigationConstant
+	}
+	acceleration = mathutils.CrossProduct(&rotation, &m.speed)
+	acceleration = acceleration.MulScalar(navigationConstant)
+
+	// The missile can't turn faster than its turn rate
+	maxAcceleration := m.model.TurnRate * m.speed.Length()
+	if length := acceleration.Length(); length > maxAcceleration {
+		acceleration = acceleration.MulScalar(maxAcceleration / length)
+	}
+	return acceleration
+}
+
+// Read writes the state of the missile in the snapshot, like a projectile's spawn record
+func (m *Missile) Read(snapshot []byte) (n int, err error) {
+
+	binary.BigEndian.PutUint16(snapshot[0:], m.id)
+	snapshot[2] = ProjectileMissile
+	snapshot[3] = m.source
+	putVector3D(snapshot[4:], m.location)
+	putVector3D(snapshot[16:], m.speed)
+
+	return ProjectileSnapshotSize, nil
+}
+
+// angleBetween returns the angle between two vectors in radians
+func angleBetween(u mathutils.Vector3D, v mathutils.Vector3D) float64 {
+
+	lengths := u.Length() * v.Length()
+	if lengths == 0 {
+		return 0
+	}
+	return math.Acos(math.Max(-1, math.Min(1, mathutils.DotProduct(&u, &v)/lengths)))
+}
+
+// launcher returns the first launcher of the plane that still has missiles
+func (p *Plane) launcher() *Launcher {
+	for _, launcher := range p.launchers {
+		if launcher.count > 0 {
+			return launcher
+		}
+	}
+	return nil
+}
+
+// updateSeekers points the seeker of every plane to the closest plane in its cone, and locks it
+// when it stays long enough. The targeted players are warned
+func (w *World) updateSeekers(deltaT float64) {
+
+	planes := w.sortedPlanes()
+
+	for _, plane := range planes {
+
+		var target *Plane
+		launcher := plane.launcher()
+
+		if launcher != nil && !plane.isDead() {
+			target = w.seek(plane, &launcher.model, planes)
+		}
+
+		seeker := &plane.seeker
+
+		if target != seeker.target {
+			if seeker.target != nil {
+				w.emitTo(seeker.target.UID, warningMessage(WarningClear, plane.UID))
+			}
+			if target != nil {
+				w.emitTo(target.UID, warningMessage(WarningLocking, plane.UID))
+			}
+			plane.seeker = Seeker{target: target}
+			continue
+		}
+
+		if target == nil || seeker.locked {
+			continue
+		}
+
+		seeker.lock += deltaT
+		if seeker.lock >= launcher.model.LockTime {
+			seeker.locked = true
+			w.emitTo(target.UID, warningMessage(WarningLocked, plane.UID))
+		}
+	}
+}
+
+// seek returns the plane that is the closest to the axis of the seeker of "plane", in its range
+func (w *World) seek(plane *Plane, model *MissileModel, planes []*Plane) (target *Plane) {
+
+	nose := mathutils.Vector3D{X: 0, Y: 0, Z: 1}
+	nose = nose.MultiplyByMatrix3(&plane.orientation)
+
+	bestAngle := model.SeekerCone
+
+	for _, other := range planes {
+
+		if other == plane || other.isDead() || (plane.team != 0 && other.team == plane.team) {
+			continue
+		}
+
+		lineOfSight := other.location.Sub(plane.location)
+		if lineOfSight.Length() > model.SeekerRange {
+			continue
+		}
+
+		if angle := angleBetween(nose, lineOfSight); angle <= bestAngle {
+			target = other
+			bestAngle = angle
+		}
+	}
+	return target
+}
+
+// launchMissiles launches a missile for every plane that asks for it. It's guided to the target
+// of the plane's seeker if it's locked, or flies blind otherwise
+func (w *World) launchMissiles(deltaT float64) {
+
+	for _, plane := range w.sortedPlanes() {
+
+		plane.launchCooldown -= deltaT
+
+		launcher := plane.launcher()
+
+		if !plane.input.IsLaunching || plane.isDead() || launcher == nil || plane.launchCooldown > 0 {
+			continue
+		}
+
+		launcher.count--
+		plane.launchCooldown = missileLaunchInterval
+
+		missile := NewMissile(plane.UID, launcher.model, plane.location, plane.speed, nil)
+
+		if target := plane.seeker.target; plane.seeker.locked {
+			missile.target = target
+			w.emitTo(target.UID, warningMessage(WarningLaunch, plane.UID))
+		}
+		w.addMissile(missile)
+	}
+}
+
+// addMissile adds the missile in the world. It shares the ids of the bullets
+func (w *World) addMissile(missile *Missile) {
+
+	missile.id = w.nextProjectileID
+	w.nextProjectileID++
+
+	w.missiles = append(w.missiles, missile)
+}
+
+// updateMissiles moves the missiles and removes the ones that are too old
+func (w *World) updateMissiles(deltaT float64) {
+
+	missilesStillAlive := []*Missile{}
+
+	for _, missile := range w.missiles {
+
+		if missile.Update(deltaT, w.atmosphere) {
+			missilesStillAlive = append(missilesStillAlive, missile)
+		} else {
+			w.despawned = append(w.despawned, missile.id)
+		}
+	}
+	w.missiles = missilesStillAlive
+}
+
+// detectDetonations explodes the missiles that pass close enough to a plane, and removes the ones
+// that hit the ground
+func (w *World) detectDetonations() {
+
+	missilesStillAlive := []*Missile{}
+
+	for _, missile := range w.missiles {
+
+		if victim := w.proximity(missile); victim != nil {
+			w.hurt(missile.source, victim, missile.model.Damage)
+			w.despawned = append(w.despawned, missile.id)
+			continue
+		}
+
+		if w.terrain != nil {
+			if _, _, hit := w.terrain.SegmentIntersection(missile.previous, missile.location); hit {
+				w.despawned = append(w.despawned, missile.id)
+				continue
+			}
+		}
+		missilesStillAlive = append(missilesStillAlive, missile)
+	}
+	w.missiles = missilesStillAlive
+}
+
+// proximity returns the plane that is the closest to the path of the missile during the last tick,
+// if it's in the range of the fuse
+func (w *World) proximity(missile *Missile) (victim *Plane) {
+
+	closest := math.Inf(1)
+
+	for _, entity := range w.grid.alongSegment(missile.previous, missile.location, missile.model.ProximityFuse) {
+
+		plane, isPlane := entity.(*Plane)
+		if !isPlane || plane.UID == missile.source || plane.isDead() {
+			continue
+		}
+
+		point := mathutils.ClosestPointOnSegment(plane.location, missile.previous, missile.location)
+		if distance := mathutils.Distance(point, plane.location); distance < closest {
+			victim = plane
+			closest = distance
+		}
+	}
+	return victim
+}
+
+// warningMessage warns a player about a seeker or a missile:
+// 0xB|warning|uid of the plane that targets the player
+func warningMessage(warning uint8, source uint8) []byte {
+
+	message := make([]byte, 1+1+1)
+	message[0] = 0xB // Missile warning
+	message[1] = warning
+	message[2] = source
+	return message
+}
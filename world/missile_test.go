@@ -0,0 +1,166 @@
+package world
+
+import (
+	"math"
+	"testing"
+
+	"github.com/eaglesight/eaglesight-server/mathutils"
+)
+
+func dummyMissileModel() MissileModel {
+	return MissileModel{
+		Count:         2,
+		SeekerCone:    0.5,
+		SeekerRange:   3000,
+		LockTime:      1,
+		Acceleration:  300,
+		BurnTime:      4,
+		TurnRate:      0.6,
+		ProximityFuse: 10,
+		Damage:        80,
+		Lifetime:      10,
+	}
+}
+
+// missileWorld returns a world with a shooter (1) looking at a target (2), 1000 units ahead
+func missileWorld() (w *World, shooter *Plane, target *Plane) {
+	w = getTestWorld()
+
+	w.addPlane(1, 0, PlaneModel{Life: 100, Missiles: []MissileModel{dummyMissileModel()}}, w.gun)
+	w.addPlane(2, 0, PlaneModel{Life: 100}, w.gun)
+
+	shooter = w.planes[1]
+	target = w.planes[2]
+	shooter.location = mathutils.Vector3D{X: 2000, Y: 1500, Z: 2000}
+	target.location = mathutils.Vector3D{X: 2100, Y: 1500, Z: 3000}
+	return w, shooter, target
+}
+
+func TestLaunchInput(t *testing.T) {
+	plane, _ := dummyPlane(3)
+
+	plane.Write([]byte{0x3, 0, 0, 0, 0, 0x40})
+
+	if !plane.input.IsLaunching || plane.input.IsFiring {
+		t.Errorf("Wrong input: %+v", plane.input)
+	}
+}
+
+func TestSeekerLock(t *testing.T) {
+	w, shooter, target := missileWorld()
+
+	w.updateSeekers(0.5)
+
+	if shooter.seeker.target != target || shooter.seeker.locked {
+		t.Fatal("The seeker should be locking the target")
+	}
+
+	w.updateSeekers(0.5)
+	w.updateSeekers(0.5)
+
+	if !shooter.seeker.locked {
+		t.Fatal("The target should be locked")
+	}
+
+	warnings := eventsWithOpcode(w, 0xB)
+	if len(warnings) != 2 || warnings[0][1] != WarningLocking || warnings[1][1] != WarningLocked || warnings[1][2] != 1 {
+		t.Errorf("The target should be warned: %v", warnings)
+	}
+
+	for _, event := range w.events {
+		if event.Data[0] == 0xB && (!event.Private || event.Recipient != 2) {
+			t.Error("Only the target should be warned")
+		}
+	}
+
+	// The target escapes the cone
+	target.location.Z = 1000
+	w.updateSeekers(0.5)
+
+	warnings = eventsWithOpcode(w, 0xB)
+	if shooter.seeker.target != nil || warnings[len(warnings)-1][1] != WarningClear {
+		t.Error("The lock should be lost")
+	}
+}
+
+func TestLaunchMissile(t *testing.T) {
+	w, shooter, target := missileWorld()
+
+	for i := 0; i < 3; i++ {
+		w.updateSeekers(0.5)
+	}
+
+	shooter.input.IsLaunching = true
+	w.launchMissiles(0.01)
+	// Too soon for the second one
+	w.launchMissiles(0.01)
+
+	if len(w.missiles) != 1 || w.missiles[0].target != target || shooter.launchers[0].count != 1 {
+		t.Fatalf("One missile should be launched at the target: %d", len(w.missiles))
+	}
+
+	warnings := eventsWithOpcode(w, 0xB)
+	if warnings[len(warnings)-1][1] != WarningLaunch {
+		t.Error("The target should be warned about the launch")
+	}
+
+	snapshot := w.generateSnapshots()
+	// Before the NPCs' count
+	missiles := len(snapshot) - 2 - ProjectileSnapshotSize - 2
+	if snapshot[missiles+2+2] != ProjectileMissile || snapshot[missiles+2+3] != 1 {
+		t.Errorf("The missile should be in the snapshot: %v", snapshot[missiles:])
+	}
+}
+
+func TestProportionalNavigation(t *testing.T) {
+	atmosphere := Atmosphere{}.withDefaults()
+	target, _ := dummyPlane(2)
+
+	chase := func(guided bool) (closest float64) {
+		target.location = mathutils.Vector3D{X: 0, Y: 2000, Z: 2000}
+		target.speed = mathutils.Vector3D{X: 200, Y: 0, Z: 0}
+
+		var seeking trackable
+		if guided {
+			seeking = target
+		}
+		missile := NewMissile(1, dummyMissileModel(), mathutils.Vector3D{X: 0, Y: 2000, Z: 0}, mathutils.Vector3D{X: 0, Y: 0, Z: 300}, seeking)
+
+		closest = math.Inf(1)
+		for i := 0; i < 1000 && missile.Update(0.01, &atmosphere); i++ {
+			target.location = target.location.Add(target.speed.MulScalar(0.01))
+
+			point := mathutils.ClosestPointOnSegment(target.location, missile.previous, missile.location)
+			closest = math.Min(closest, mathutils.Distance(point, target.location))
+		}
+		return closest
+	}
+
+	if closest := chase(true); closest > dummyMissileModel().ProximityFuse {
+		t.Errorf("The missile should reach the crossing target, it missed by %f", closest)
+	}
+
+	if closest := chase(false); closest < 100 {
+		t.Errorf("A blind missile should miss, it passed at %f", closest)
+	}
+}
+
+func TestDetonation(t *testing.T) {
+	w, _, target := missileWorld()
+
+	missile := NewMissile(1, dummyMissileModel(), target.location, mathutils.Vector3D{X: 0, Y: 0, Z: 600}, nil)
+	missile.previous = target.location.Sub(mathutils.Vector3D{X: 8, Y: 0, Z: 30})
+	missile.location = target.location.Add(mathutils.Vector3D{X: -8, Y: 0, Z: 30})
+	w.addMissile(missile)
+
+	w.indexEntities()
+	w.detectDetonations()
+
+	if len(w.missiles) != 0 || target.life != 20 {
+		t.Errorf("The missile should explode next to the target: life is %d", target.life)
+	}
+
+	if len(w.despawned) != 1 || w.despawned[0] != missile.id {
+		t.Error("The missile should be despawned")
+	}
+}
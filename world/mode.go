@@ -0,0 +1,182 @@
+package world
+
+import (
+	"log"
+)
+
+// Game modes of the rules
+const (
+	// ModeDeathmatch : every player for itself. The most kills wins
+	ModeDeathmatch = "deathmatch"
+	// ModeTeamDeathmatch : the team with the most kills wins
+	ModeTeamDeathmatch = "teamDeathmatch"
+	// ModeLastManStanding : no respawn during the match. The last plane flying wins
+	ModeLastManStanding = "lastManStanding"
+)
+
+// GameMode decides how the players score and win. The world calls its hooks during the match
+type GameMode interface {
+	// Start is called when a match starts, once the planes are on their spawn points
+	Start(w *World)
+	// Join is called when a plane joins a match that already started
+	Join(w *World, plane *Plane)
+	// Leave is called when a plane leaves during the match
+	Leave(w *World, uid uint8)
+	// PlaneDestroyed is called when a plane is no more. "killer" is the plane itself when nobody destroyed it
+	PlaneDestroyed(w *World, plane *Plane, killer uint8)
+	// Tick is called at every tick of the match
+	Tick(w *World, deltaT float64)
+	// CanRespawn returns whether a plane that is no more can come back
+	CanRespawn(w *World, plane *Plane) bool
+	// Standings returns the points of the contenders (players or teams). The score and time limits use them
+	Standings(w *World) map[uint8]int
+	// IsOver returns whether the match is won before the limits, and its winner
+	IsOver(w *World) (over bool, winner uint8)
+	// Armed returns whether the planes and the NPCs can use their weapons and countermeasures
+	Armed() bool
+}
+
+// newGameMode returns the game mode called "name". Deathmatch by default
+func newGameMode(name string) GameMode {
+
+	switch name {
+	case ModeDeathmatch, "":
+		return &Deathmatch{}
+	case ModeTeamDeathmatch:
+		return &TeamDeathmatch{}
+	case ModeLastManStanding:
+		return &LastManStanding{}
+	case ModeAirRace:
+		return &AirRace{}
+	}
+
+	log.Printf("Unknown game mode %q, playing %s\n", name, ModeDeathmatch)
+	return &Deathmatch{}
+}
+
+// noHooks does nothing when something happens. The game modes only implement what they need
+type noHooks struct{}
+
+func (noHooks) Start(w *World)                                      {}
+func (noHooks) Join(w *World, plane *Plane)                         {}
+func (noHooks) Leave(w *World, uid uint8)                           {}
+func (noHooks) PlaneDestroyed(w *World, plane *Plane, killer uint8) {}
+func (noHooks) Tick(w *World, deltaT float64)                       {}
+
+// Deathmatch : every player for itself
+type Deathmatch struct {
+	noHooks
+}
+
+// CanRespawn : always
+func (d *Deathmatch) CanRespawn(w *World, plane *Plane) bool {
+	return true
+}
+
+// Standings returns the kills of every player
+func (d *Deathmatch) Standings(w *World) map[uint8]int {
+
+	standings := make(map[uint8]int)
+	for uid, score := range w.scoreboard.Scores() {
+		standings[uid] = int(score.Kills)
+	}
+	return standings
+}
+
+// IsOver : only the limits end a deathmatch
+func (d *Deathmatch) IsOver(w *World) (over bool, winner uint8) {
+	return false, NoWinner
+}
+
+// Armed : yes
+func (d *Deathmatch) Armed() bool {
+	return true
+}
+
+// TeamDeathmatch : the kills of the players count for their team
+type TeamDeathmatch struct {
+	noHooks
+}
+
+// CanRespawn : always
+func (t *TeamDeathmatch) CanRespawn(w *World, plane *Plane) bool {
+	return true
+}
+
+// Standings returns the points of every team
+func (t *TeamDeathmatch) Standings(w *World) map[uint8]int {
+	return w.scoreboard.TeamScores()
+}
+
+// IsOver : only the limits end a team deathmatch
+func (t *TeamDeathmatch) IsOver(w *World) (over bool, winner uint8) {
+	return false, NoWinner
+}
+
+// Armed : yes
+func (t *TeamDeathmatch) Armed() bool {
+	return true
+}
+
+// LastManStanding : the planes that are destroyed wait for the next match
+type LastManStanding struct {
+	noHooks
+	contenders map[uint8]bool // Planes still in the match
+	started    bool           // There were at least 2 contenders at the start
+}
+
+// Start : every plane is a contender
+func (l *LastManStanding) Start(w *World) {
+
+	l.contenders = make(map[uint8]bool)
+	for uid := range w.planes {
+		l.contenders[uid] = true
+	}
+	l.started = len(l.contenders) >= 2
+}
+
+// Leave : the plane is out
+func (l *LastManStanding) Leave(w *World, uid uint8) {
+	delete(l.contenders, uid)
+}
+
+// PlaneDestroyed : the plane is out
+func (l *LastManStanding) PlaneDestroyed(w *World, plane *Plane, killer uint8) {
+	delete(l.contenders, plane.UID)
+}
+
+// CanRespawn : only between the matches. The latecomers wait too
+func (l *LastManStanding) CanRespawn(w *World, plane *Plane) bool {
+	return !w.playing()
+}
+
+// Standings returns the kills of the contenders, when the time is up
+func (l *LastManStanding) Standings(w *World) map[uint8]int {
+
+	standings := make(map[uint8]int)
+	scores := w.scoreboard.Scores()
+
+	for uid := range l.contenders {
+		standings[uid] = int(scores[uid].Kills)
+	}
+	return standings
+}
+
+// IsOver returns whether there is only one plane left (or none). A match that started with
+// less than 2 planes has nobody to fight: only the limits can end it
+func (l *LastManStanding) IsOver(w *World) (over bool, winner uint8) {
+
+	if !l.started || len(l.contenders) > 1 {
+		return false, NoWinner
+	}
+
+	for uid := range l.contenders {
+		return true, uid
+	}
+	return true, NoWinner
+}
+
+// Armed : yes
+func (l *LastManStanding) Armed() bool {
+	return true
+}
@@ -0,0 +1,159 @@
+package world
+
+import (
+	"testing"
+)
+
+// kill destroys the plane "victim" with the plane "killer" and lets the match go on
+func kill(w *World, killer uint8, victim uint8) {
+	w.hurt(killer, w.planes[victim], 255)
+	w.detectDeaths()
+	w.updateMatch(0.01)
+}
+
+func TestGameModes(t *testing.T) {
+
+	tests := map[string]GameMode{
+		"":                  &Deathmatch{},
+		ModeDeathmatch:      &Deathmatch{},
+		ModeTeamDeathmatch:  &TeamDeathmatch{},
+		ModeLastManStanding: &LastManStanding{},
+		ModeAirRace:         &AirRace{},
+		"capture the flag":  &Deathmatch{},
+	}
+
+	for name, expected := range tests {
+		if mode := newGameMode(name); typeName(mode) != typeName(expected) {
+			t.Errorf("%q should be a %s, not a %s", name, typeName(expected), typeName(mode))
+		}
+	}
+}
+
+func typeName(mode GameMode) string {
+	switch mode.(type) {
+	case *Deathmatch:
+		return "deathmatch"
+	case *TeamDeathmatch:
+		return "team deathmatch"
+	case *LastManStanding:
+		return "last man standing"
+	case *AirRace:
+		return "air race"
+	}
+	return "?"
+}
+
+func TestDeathmatch(t *testing.T) {
+	w := newTestWorld(Scenario{}, Rules{RespawnDelay: 1, Mode: ModeDeathmatch, Match: MatchRules{MinPlayers: 3, ScoreLimit: 2}}, 1, 1, 2)
+
+	kill(w, 3, 1)
+	kill(w, 1, 3)
+	if w.phase != MatchLive {
+		t.Fatal("Nobody has 2 kills yet")
+	}
+
+	kill(w, 3, 2)
+	if w.phase != MatchEnded || w.winner != 3 {
+		t.Errorf("The plane 3 should win: phase %d, winner %d", w.phase, w.winner)
+	}
+}
+
+func TestTeamDeathmatch(t *testing.T) {
+	w := newTestWorld(Scenario{}, Rules{RespawnDelay: 1, Mode: ModeTeamDeathmatch, Match: MatchRules{MinPlayers: 3, ScoreLimit: 2}}, 1, 1, 2)
+
+	kill(w, 1, 3)
+	w.waitForRespawn(3, 1)
+	kill(w, 2, 3)
+
+	if w.phase != MatchEnded || w.winner != 1 {
+		t.Errorf("The team 1 should win: phase %d, winner %d", w.phase, w.winner)
+	}
+}
+
+func TestLastManStanding(t *testing.T) {
+	w := newTestWorld(Scenario{}, Rules{RespawnDelay: 1, Mode: ModeLastManStanding, Match: MatchRules{MinPlayers: 3}}, 1, 1, 2)
+
+	kill(w, 3, 1)
+	w.waitForRespawn(1, 10)
+
+	if !w.planes[1].isDead() || w.phase != MatchLive {
+		t.Fatal("The plane 1 should not respawn during the match")
+	}
+
+	// A latecomer waits for the next match
+	w.addPlane(4, 2, PlaneModel{Life: 100}, w.gun)
+	w.detectDeaths()
+	if !w.planes[4].isDead() || len(eventsWithOpcode(w, 0xE)) != 1 {
+		t.Fatal("The latecomer should wait, without dying")
+	}
+
+	kill(w, 2, 3)
+	if w.phase != MatchEnded || w.winner != 2 {
+		t.Fatalf("The plane 2 should win: phase %d, winner %d", w.phase, w.winner)
+	}
+
+	// The match is over, everybody can come back
+	w.waitForRespawn(1, 10)
+	if w.planes[1].isDead() {
+		t.Error("The plane 1 should respawn after the match")
+	}
+}
+
+func TestLastManStandingDefaultRules(t *testing.T) {
+	// Nobody to fight yet: the match waits, and the lonely plane keeps flying
+	w := newTestWorld(Scenario{}, Rules{RespawnDelay: 1, Mode: ModeLastManStanding}, 0)
+	w.updateMatch(0.01)
+
+	if w.phase != MatchWaiting || w.planes[1].isDead() {
+		t.Fatalf("The match should wait for an opponent: phase %d", w.phase)
+	}
+
+	// The second plane starts the match
+	w.addPlane(2, 0, PlaneModel{Life: 100}, w.gun)
+	w.updateMatch(0.01)
+
+	if w.phase != MatchLive || w.planes[1].isDead() || w.planes[2].isDead() {
+		t.Fatalf("The match should start with both planes: phase %d", w.phase)
+	}
+
+	kill(w, 1, 2)
+
+	if w.phase != MatchEnded || w.winner != 1 {
+		t.Errorf("The last plane flying should win: phase %d, winner %d", w.phase, w.winner)
+	}
+}
+
+func TestMatchEndsOncePerTick(t *testing.T) {
+	w := newTestWorld(Scenario{}, Rules{Match: MatchRules{ScoreLimit: 1, Restart: true}}, 0, 0)
+
+	w.hurt(1, w.planes[2], 255)
+	w.detectDeaths()
+	w.updateMatch(0.01)
+
+	if w.phase != MatchEnded || len(w.Over) != 1 {
+		t.Fatalf("The match should end: phase %d", w.phase)
+	}
+
+	// One phase at a time out of the end
+	w.updateMatch(0.01)
+	if w.phase != MatchWaiting {
+		t.Fatalf("The match should wait on the next tick: phase %d", w.phase)
+	}
+
+	w.updateMatch(0.01)
+	if w.phase != MatchLive || w.Scoreboard().Scores()[1].Kills != 0 {
+		t.Errorf("A new match should start: phase %d", w.phase)
+	}
+}
+
+func TestLastManLeaves(t *testing.T) {
+	w := newTestWorld(Scenario{}, Rules{RespawnDelay: 1, Mode: ModeLastManStanding, Match: MatchRules{MinPlayers: 3}}, 1, 1, 2)
+
+	w.removePlane(1)
+	w.removePlane(2)
+	w.updateMatch(0.01)
+
+	if w.phase != MatchEnded || w.winner != 3 {
+		t.Errorf("The plane 3 should win: phase %d, winner %d", w.phase, w.winner)
+	}
+}
@@ -0,0 +1,175 @@
+package world
+
+import (
+	"math"
+
+	"github.com/eaglesight/eaglesight-server/mathutils"
+)
+
+const (
+	// NPCSource is the source of the projectiles fired by the NPCs. It's reserved: no player can have this UID
+	NPCSource = 0xFE
+	// NPCSnapshotSize : uint8 (id) + uint8 (firing + damage) + float32 * 3 (aim)
+	NPCSnapshotSize = 1 + 1 + (3 * 4)
+	// turretFiringAngle is how far (radians) a turret can aim from its firing solution and still fire
+	turretFiringAngle = 0.05
+)
+
+// IsReservedUID returns whether the uid is used by the world itself, so no player can have it
+func IsReservedUID(uid uint8) bool {
+	return uid == NPCSource || uid == NoWinner
+}
+
+// NPC is an entity driven by the server, not by a player
+type NPC interface {
+	damageable
+	// think updates the NPC. It can look at the world and fire through its gun channel
+	think(deltaT float64, w *World)
+	// Read writes the state of the NPC in the snapshot
+	Read(snapshot []byte) (n int, err error)
+}
+
+// TurretModel makes a ground target an anti-aircraft gun
+type TurretModel struct {
+	Range    float64  `json:"range"`    // Planes farther than this are ignored
+	TurnRate float64  `json:"turnRate"` // radians / seconds
+	Gun      GunModel `json:"gun"`
+}
+
+// Turret is an anti-aircraft gun that shoots the closest enemy plane
+type Turret struct {
+	*GroundTarget
+	turret TurretModel
+	gun    *Gun
+	aim    mathutils.Vector3D // Where the gun points (unit vector)
+	firing bool
+}
+
+// NewTurret mounts a turret on the ground target
+func NewTurret(target *GroundTarget, model TurretModel) *Turret {
+	return &Turret{
+		GroundTarget: target,
+		turret:       model,
+		gun:          NewGun(model.Gun),
+		aim:          mathutils.Vector3D{X: 0, Y: 1, Z: 0},
+	}
+}
+
+// think turns the turret toward the closest enemy plane, leading it, and fires when it's aimed
+func (t *Turret) think(deltaT float64, w *World) {
+
+	t.firing = false
+	target := t.closestEnemy(w)
+
+	if target != nil {
+		solution := t.firingSolution(target)
+		t.turnTo(solution, deltaT)
+		t.firing = angleBetween(t.aim, solution) <= turretFiringAngle
+	}
+
+	rounds := t.gun.update(deltaT, t.firing)
+	for i := 0; i < rounds; i++ {
+		t.fire(w)
+	}
+}
+
+// closestEnemy returns the closest plane in range that isn't in the team of the turret
+func (t *Turret) closestEnemy(w *World) (enemy *Plane) {
+
+	closest := t.turret.Range
+	team := t.GroundTarget.model.Team
+
+	for _, plane := range w.sortedPlanes() {
+
+		if plane.isDead() || (team != 0 && plane.team == team) {
+			continue
+		}
+
+		if distance := mathutils.Distance(plane.location, t.location); distance <= closest {
+			enemy = plane
+			closest = distance
+		}
+	}
+	return enemy
+}
+
+// firingSolution returns the direction where the bullets will meet the plane if it keeps flying straight
+func (t *Turret) firingSolution(plane *Plane) mathutils.Vector3D {
+
+	lineOfSight := plane.location.Sub(t.location)
+
+	// First order: the plane moves while the bullet flies to where it is now
+	flightTime := lineOfSight.Length() / t.turret.Gun.MuzzleSpeed
+	lead := plane.speed.MulScalar(flightTime)
+	solution := lineOfSight.Add(lead)
+
+	return solution.DivScalar(solution.Length())
+}
+
+// turnTo turns the aim of the turret toward "direction", not faster than the turn rate
+func (t *Turret) turnTo(direction mathutils.Vector3D, deltaT float64) {
+
+	angle := angleBetween(t.aim, direction)
+	maxAngle := t.turret.TurnRate * deltaT
+
+	if angle <= maxAngle {
+		t.aim = direction
+		return
+	}
+
+	// Spherical interpolation from the aim to the direction
+	ratio := maxAngle / angle
+	from := t.aim.MulScalar(math.Sin((1-ratio)*angle) / math.Sin(angle))
+	to := direction.MulScalar(math.Sin(ratio*angle) / math.Sin(angle))
+	t.aim = from.Add(to)
+	t.aim = t.aim.DivScalar(t.aim.Length())
+}
+
+// fire sends a bullet in the world, like a plane
+func (t *Turret) fire(w *World) {
+
+	dispersion := t.turret.Gun.Dispersion
+	deviation := mathutils.Vector3D{
+		X: w.random.NormFloat64() * dispersion,
+		Y: w.random.NormFloat64() * dispersion,
+		Z: w.random.NormFloat64() * dispersion,
+	}
+	direction := t.aim.Add(deviation)
+	direction = direction.DivScalar(direction.Length())
+
+	bullet := NewBullet(NPCSource, t.location, direction.MulScalar(t.turret.Gun.MuzzleSpeed), t.turret.Gun.Damage, t.gun.bulletLifetime())
+
+	select {
+	case w.gun <- bullet:
+	default:
+		// The world can't take more bullets for now. This one is lost.
+	}
+}
+
+// Read writes the state of the turret in the snapshot
+func (t *Turret) Read(snapshot []byte) (n int, err error) {
+
+	snapshot[0] = t.id
+	snapshot[1] = t.damagePercentage()
+	if t.firing {
+		snapshot[1] |= 0x80
+	}
+	putVector3D(snapshot[2:], t.aim)
+
+	return NPCSnapshotSize, nil
+}
+
+// updateNPCs lets the NPCs that are still there think
+func (w *World) updateNPCs(deltaT float64) {
+
+	// The NPCs wait for the match too, and respect the game mode
+	if !w.playing() || !w.mode.Armed() {
+		return
+	}
+
+	for _, npc := range w.npcs {
+		if !npc.isDead() {
+			npc.think(deltaT, w)
+		}
+	}
+}
@@ -0,0 +1,122 @@
+package world
+
+import (
+	"encoding/binary"
+	"testing"
+
+	"github.com/eaglesight/eaglesight-server/mathutils"
+)
+
+// turretWorld returns a world with a flak turret of the team 1 at (1000, ground, 1000)
+func turretWorld() (*World, *Turret) {
+	w := getTestWorld()
+
+	gun := dummyGunModel()
+	gun.Ammo = 1000
+	w.scenario.Targets = []GroundTargetModel{
+		{
+			Name:      "flak",
+			Kind:      "aa",
+			Location:  mathutils.Vector3D{X: 1000, Y: 0, Z: 1000},
+			Life:      50,
+			HitRadius: 5,
+			Team:      1,
+			Turret:    &TurretModel{Range: 1500, TurnRate: 1, Gun: gun},
+		},
+	}
+	w.placeTargets()
+
+	return w, w.npcs[0].(*Turret)
+}
+
+// enemyAbove adds the plane 2 of the team 2 above the turret
+func enemyAbove(w *World, turret *Turret, height float64) *Plane {
+	w.addPlane(2, 0, PlaneModel{Life: 100}, w.gun)
+	plane := w.planes[2]
+	plane.team = 2
+	plane.location = turret.location.Add(mathutils.Vector3D{X: 200, Y: height, Z: 0})
+	plane.speed = mathutils.Vector3D{X: 0, Y: 0, Z: 100}
+	return plane
+}
+
+func TestTurretFires(t *testing.T) {
+	w, turret := turretWorld()
+	enemyAbove(w, turret, 800)
+
+	for i := 0; i < 200; i++ {
+		w.updateNPCs(0.01)
+	}
+	w.collectBullets()
+
+	if len(w.bullets) == 0 {
+		t.Fatal("The turret should fire at the plane")
+	}
+
+	for _, bullet := range w.bullets {
+		if bullet.source != NPCSource || bullet.speed.Y <= 0 || bullet.speed.Z <= 0 {
+			t.Fatalf("The bullet should go up and lead the plane: %+v", bullet.speed)
+		}
+	}
+}
+
+func TestTurretIgnores(t *testing.T) {
+	w, turret := turretWorld()
+
+	// Too far
+	plane := enemyAbove(w, turret, 3000)
+	w.updateNPCs(0.5)
+
+	// Same team
+	plane.location.Y = turret.location.Y + 800
+	plane.team = 1
+	w.updateNPCs(0.5)
+
+	w.collectBullets()
+	if len(w.bullets) != 0 || turret.firing {
+		t.Error("The turret should not fire")
+	}
+}
+
+func TestTurretTurnRate(t *testing.T) {
+	_, turret := turretWorld()
+
+	// A quarter turn at 1 radian per second
+	direction := mathutils.Vector3D{X: 1, Y: 0, Z: 0}
+	turret.turnTo(direction, 0.5)
+
+	if angle := angleBetween(turret.aim, direction); !isClose(angle, 3.14159265358979/2-0.5, 1e-6) {
+		t.Errorf("The turret turned too fast: %f left", angle)
+	}
+}
+
+func TestDestroyTurret(t *testing.T) {
+	w, turret := turretWorld()
+	enemyAbove(w, turret, 800)
+
+	// Its own bullets don't hit it
+	if _, hit := turret.isHitBy(&Bullet{source: NPCSource, previous: turret.location, location: turret.location}); hit {
+		t.Error("A turret should not be hit by the NPCs")
+	}
+
+	for i := 0; i < 5; i++ {
+		w.addBullet(&Bullet{source: 2, previous: turret.location, location: turret.location, damage: 10})
+	}
+	w.indexEntities()
+	w.detectHits(0.01)
+
+	if !turret.isDead() {
+		t.Fatal("The turret should be destroyed")
+	}
+
+	w.updateNPCs(1)
+	w.collectBullets()
+	if len(w.bullets) != 0 {
+		t.Error("A destroyed turret can't fire")
+	}
+
+	snapshot := w.generateSnapshots()
+	npcs := len(snapshot) - NPCSnapshotSize - 2
+	if binary.BigEndian.Uint16(snapshot[npcs:]) != 1 || snapshot[npcs+2] != turret.id || snapshot[npcs+3] != 100 {
+		t.Errorf("The turret should be in the snapshot: %v", snapshot[npcs:])
+	}
+}
@@ -3,6 +3,7 @@ package world
 import (
 	"encoding/binary"
 	"math"
+	"math/rand"
 
 	"github.com/eaglesight/eaglesight-server/mathutils"
 )
@@ -10,81 +11,169 @@ import (
 const (
 	// PlaneSnapshotSize : uint8 (planeId) + float32 * 3 (location) + float32 * 4 (rotation) + 1 bit for firing + 7 bits damage
 	PlaneSnapshotSize = 1 + 1 + (3 * 4) + (4 * 4)
+	// PlaneHitRadius is the radius of the hit sphere of the models that don't define one
+	PlaneHitRadius = 8
+	// PlaneCrashSpeed is the speed into the ground (m/s) above which the models that don't define one crash
+	PlaneCrashSpeed = 15
 )
 
 // PlaneInput ...
 type PlaneInput struct {
 	Roll, Pitch, Yaw, Thrust float64
 	IsFiring                 bool
+	IsLaunching              bool
+	IsDeploying              bool // Countermeasures
+	IsBombing                bool
 }
 
 // PlaneModel are all the constant properties that can easily be loaded from a JSON object
 type PlaneModel struct {
-	MaxThrust    float64            `json:"maxThrust"`
-	Mass         float64            `json:"mass"`
-	MaxRotations mathutils.Vector3D `json:"maxRotations"` // All in radians / seconds
-	DragFactors  mathutils.Vector3D `json:"dragFactors"`  // A drag factor are "Area * Drag Coeficient * 0.5" of a side
-	LiftMin      float64            `json:"liftMin"`
-	LiftMax      float64            `json:"liftMax"`
-	DefaultSpeed float64            `json:"defaultSpeed"` // Default speed of the plane on the Z axis
-	Life         uint8              `json:"life"`
+	MaxThrust     float64            `json:"maxThrust"`
+	Mass          float64            `json:"mass"`
+	MaxRotations  mathutils.Vector3D `json:"maxRotations"`  // All in radians / seconds
+	DragFactors   mathutils.Vector3D `json:"dragFactors"`   // A drag factor are "Area * Drag Coeficient * 0.5" of a side
+	WingArea      float64            `json:"wingArea"`      // m²
+	LiftZero      float64            `json:"liftZero"`      // Lift coefficient at 0 angle of attack
+	LiftSlope     float64            `json:"liftSlope"`     // Lift coefficient gained per radian of angle of attack
+	CriticalAngle float64            `json:"criticalAngle"` // Angle of attack (radians) above which the wing stalls
+	InducedDrag   float64            `json:"inducedDrag"`   // Induced drag coefficient = InducedDrag * (lift coefficient)²
+	DefaultSpeed  float64            `json:"defaultSpeed"`  // Default speed of the plane on the Z axis
+	Life          uint8              `json:"life"`
+	HitRadius     float64            `json:"hitRadius"`  // Radius of the sphere around the plane used for hits and collisions
+	CrashSpeed    float64            `json:"crashSpeed"` // Hitting the ground faster than this (m/s into the ground) destroys the plane
+	// Engine
+	FuelCapacity         float64 `json:"fuelCapacity"`         // kg. 0 means unlimited fuel
+	FuelBurnRate         float64 `json:"fuelBurnRate"`         // kg / seconds at full dry power
+	SpoolTime            float64 `json:"spoolTime"`            // Seconds for the engine to go from idle to full power
+	AfterburnerThrust    float64 `json:"afterburnerThrust"`    // Extra thrust when the afterburner is lit. 0 means no afterburner
+	AfterburnerBurnRate  float64 `json:"afterburnerBurnRate"`  // Extra kg / seconds burnt by the afterburner
+	AfterburnerThreshold float64 `json:"afterburnerThreshold"` // Throttle (0 to 1) above which the afterburner is lit
+	// Weapons
+	Guns     []GunModel     `json:"guns"`
+	Missiles []MissileModel `json:"missiles"`
+	Bombs    []BombModel    `json:"bombs"`
+	// Countermeasures
+	Flares uint8 `json:"flares"`
+	Chaff  uint8 `json:"chaff"`
 }
 
 // Plane describe a plane with all its properties
 type Plane struct {
-	UID                uint8
-	input              PlaneInput
-	model              PlaneModel
-	location           mathutils.Vector3D // Absolute Location in the world
-	speed              mathutils.Vector3D // unit / seconds
-	orientation        mathutils.Matrix3
-	orientationInverse mathutils.Matrix3
-	life               uint8
-	isNoMore           bool
-	gun                chan<- *Bullet
+	UID                    uint8
+	team                   uint8 // 0 means no team
+	input                  PlaneInput
+	model                  PlaneModel
+	location               mathutils.Vector3D // Absolute Location in the world
+	speed                  mathutils.Vector3D // unit / seconds
+	orientation            mathutils.Matrix3
+	orientationInverse     mathutils.Matrix3
+	life                   uint8
+	isNoMore               bool
+	cause                  uint8          // Why the plane is no more (PlaneDestroyed, PlaneCrashed)
+	zone                   uint8          // Zone of the battle area reported to the player
+	timeOutside            float64        // Seconds spent out of the battle area
+	damageLog              []damageRecord // Hits taken since the last respawn
+	race                   *RaceProgress  // nil when it's not racing
+	engine                 Engine
+	guns                   []*Gun
+	launchers              []*Launcher
+	seeker                 Seeker
+	launchCooldown         float64 // Seconds before the next missile can be launched
+	bombBays               []*BombBay
+	bombCooldown           float64 // Seconds before the next bomb can be dropped
+	flares                 uint8
+	chaff                  uint8
+	countermeasureCooldown float64 // Seconds before the next countermeasures can be deployed
+	gun                    chan<- *Bullet
+	random                 *rand.Rand // Seeded with the UID so a replay fires the same bullets
 }
 
 // NewPlane fill the plane with its default properties
 func NewPlane(uid uint8, model PlaneModel, gun chan<- *Bullet) (plane *Plane) {
 
 	plane = &Plane{
-		UID: uid,
-		input: PlaneInput{
-			Roll:     0,
-			Pitch:    0,
-			Yaw:      0,
-			Thrust:   0,
-			IsFiring: false,
-		},
-		location: mathutils.Vector3D{
-			X: 0,
-			Y: 1500, // Find a way to set location
-			Z: 0,
-		},
-		orientation: mathutils.NewMatrix3(),
-		speed: mathutils.Vector3D{
-			X: 0,
-			Y: 0,
-			Z: model.DefaultSpeed,
-		},
-		model:    model,
-		life:     model.Life,
-		isNoMore: false,
-		gun:      gun,
+		UID:    uid,
+		model:  model,
+		gun:    gun,
+		random: rand.New(rand.NewSource(int64(uid))),
 	}
+	plane.respawn(defaultSpawnPoint)
+
 	return plane
 }
 
+// respawn puts the plane back at "spawn" in its default state: full life, loaded guns, etc.
+func (p *Plane) respawn(spawn SpawnPoint) {
+
+	p.input = PlaneInput{
+		Roll:        0,
+		Pitch:       0,
+		Yaw:         0,
+		Thrust:      0,
+		IsFiring:    false,
+		IsLaunching: false,
+		IsDeploying: false,
+		IsBombing:   false,
+	}
+	p.location = spawn.Location
+	p.orientation = mathutils.MakeMatrix3Y(spawn.Heading)
+
+	speed := spawn.Speed
+	if speed == 0 {
+		speed = p.model.DefaultSpeed
+	}
+	p.speed = mathutils.Vector3D{
+		X: 0,
+		Y: 0,
+		Z: speed,
+	}
+	p.speed = p.speed.MultiplyByMatrix3(&p.orientation)
+	p.life = p.model.Life
+	p.engine = Engine{fuel: p.model.FuelCapacity}
+	p.isNoMore = false
+	p.cause = 0
+	p.timeOutside = 0
+	p.damageLog = nil
+
+	// Load the guns
+	p.guns = []*Gun{}
+	for _, gunModel := range p.model.Guns {
+		p.guns = append(p.guns, NewGun(gunModel))
+	}
+
+	// And the missiles
+	p.launchers = []*Launcher{}
+	for _, missileModel := range p.model.Missiles {
+		p.launchers = append(p.launchers, &Launcher{model: missileModel, count: missileModel.Count})
+	}
+	p.seeker = Seeker{}
+	p.launchCooldown = 0
+
+	// And the bombs
+	p.bombBays = []*BombBay{}
+	for _, bombModel := range p.model.Bombs {
+		p.bombBays = append(p.bombBays, &BombBay{model: bombModel, count: bombModel.Count})
+	}
+	p.bombCooldown = 0
+
+	p.flares = p.model.Flares
+	p.chaff = p.model.Chaff
+	p.countermeasureCooldown = 0
+}
+
 func (p *Plane) Write(data []byte) (n int, err error) {
 
-	if len(data) == 6 { // 0x3|Roll|Pitch|Yaw|Thrust|(IsFiring|...)
+	if len(data) == 6 { // 0x3|Roll|Pitch|Yaw|Thrust|(IsFiring|IsLaunching|IsDeploying|IsBombing|...)
 		// convert binary message to PlaneInput
 		p.input = PlaneInput{
-			Roll:     -float64(int8(data[1])) / 127,
-			Pitch:    float64(int8(data[2])) / 127,
-			Yaw:      float64(int8(data[3])) / 127,
-			Thrust:   float64(uint8(data[4])) / 255,
-			IsFiring: data[5] >= 0x80,
+			Roll:        -float64(int8(data[1])) / 127,
+			Pitch:       float64(int8(data[2])) / 127,
+			Yaw:         float64(int8(data[3])) / 127,
+			Thrust:      float64(uint8(data[4])) / 255,
+			IsFiring:    data[5]&0x80 != 0,
+			IsLaunching: data[5]&0x40 != 0,
+			IsDeploying: data[5]&0x20 != 0,
+			IsBombing:   data[5]&0x10 != 0,
 		}
 		return len(data), nil
 	}
@@ -92,16 +181,63 @@ func (p *Plane) Write(data []byte) (n int, err error) {
 	return 0, nil
 }
 
-func (p *Plane) fire() {
-	// Some default settings here
-	p.gun <- NewBullet(p.UID, p.location, &p.orientation, 600, 10)
+// fire sends a new bullet shot by "gun" in the world. The bullet leaves the nose of the plane
+// with a random deviation, and keeps the speed of the plane
+func (p *Plane) fire(gun *Gun) {
+	dispersion := gun.model.Dispersion
+	direction := mathutils.Vector3D{
+		X: math.Tan(p.random.NormFloat64() * dispersion),
+		Y: math.Tan(p.random.NormFloat64() * dispersion),
+		Z: 1,
+	}
+	direction = direction.DivScalar(direction.Length())
+
+	muzzleVelocity := direction.MulScalar(gun.model.MuzzleSpeed)
+	velocity := muzzleVelocity.MultiplyByMatrix3(&p.orientation)
+	velocity = velocity.Add(p.speed)
+
+	bullet := NewBullet(p.UID, p.location, velocity, gun.model.Damage, gun.bulletLifetime())
+
+	select {
+	case p.gun <- bullet:
+	default:
+		// The world can't take more bullets for now. This one is lost.
+	}
+}
+
+// updateGuns fires all the guns that can fire while the trigger is held
+func (p *Plane) updateGuns(deltaT float64) {
+	for _, gun := range p.guns {
+		rounds := gun.update(deltaT, p.input.IsFiring && !p.isDead())
+
+		for i := 0; i < rounds; i++ {
+			p.fire(gun)
+		}
+	}
+}
+
+// isFiring returns whether at least one gun is firing
+func (p *Plane) isFiring() bool {
+	if !p.input.IsFiring {
+		return false
+	}
+
+	for _, gun := range p.guns {
+		if gun.isReady() {
+			return true
+		}
+	}
+	return false
 }
 
 func (p *Plane) Read(snapshot []byte) (n int, err error) {
 	// UID
 	snapshot[0] = p.UID
-	// TODO: Dammage
-	snapshot[1] = 0
+	// Firing + Damage
+	snapshot[1] = p.damagePercentage()
+	if p.isFiring() {
+		snapshot[1] |= 0x80
+	}
 	// Location
 	binary.BigEndian.PutUint32(snapshot[2:], math.Float32bits(float32(p.location.X)))
 	binary.BigEndian.PutUint32(snapshot[6:], math.Float32bits(float32(p.location.Y)))
@@ -116,13 +252,22 @@ func (p *Plane) Read(snapshot []byte) (n int, err error) {
 }
 
 // Update updates the plane's properties from new parameters
-func (p *Plane) Update(deltaT float64, terrain *Terrain) {
+func (p *Plane) Update(deltaT float64, terrain *Terrain, atmosphere *Atmosphere) {
 	// Update the rotation
 	p.orientation = p.calculateRotation(deltaT)
+	// Update the engine
+	p.updateEngine(deltaT)
 	// Update the speed
-	p.speed = p.calculateSpeed(deltaT)
+	p.speed = p.calculateSpeed(deltaT, atmosphere.Density(p.location.Y), atmosphere.Wind.At(p.location))
+	previous := p.location
 	p.location = p.location.Add(p.speed.MulScalar(deltaT))
 	p.CorrectFromCollision(terrain)
+	// Did it fly through the next ring?
+	if p.race != nil {
+		p.race.update(previous, p.location, deltaT)
+	}
+	// Fire!
+	p.updateGuns(deltaT)
 }
 
 func (p *Plane) calculateRotation(deltaT float64) mathutils.Matrix3 {
@@ -136,19 +281,20 @@ func (p *Plane) calculateRotation(deltaT float64) mathutils.Matrix3 {
 	return p.orientation.Mul(localRotMat)
 }
 
-func (p *Plane) calculateSpeed(deltaT float64) mathutils.Vector3D {
+func (p *Plane) calculateSpeed(deltaT float64, airDensity float64, wind mathutils.Vector3D) mathutils.Vector3D {
 
 	localAcceleration := mathutils.Vector3D{
 		X: 0,
-		Y: p.calculateLift(),
-		Z: p.calculateThrust(),
-	}
-	// Compute the drag
-	localDrag := p.calculateDrag()
-	// Divide the force by the mass
-	localDrag = localDrag.DivScalar(p.model.Mass)
-	// Add the drag to the local acceleration
-	localAcceleration = localAcceleration.Add(localDrag)
+		Y: 0,
+		Z: p.calculateThrust(airDensity),
+	}
+	// Compute the aerodynamic forces: lift (with the induced drag) and drag
+	localForces := p.calculateLift(airDensity, wind)
+	localForces = localForces.Add(p.calculateDrag(airDensity, wind))
+	// Divide the forces by the mass
+	localForces = localForces.DivScalar(p.mass())
+	// Add them to the local acceleration
+	localAcceleration = localAcceleration.Add(localForces)
 	// Convert to global
 	globalAcceleration := localAcceleration.MultiplyByMatrix3(&p.orientation)
 	// Apply gravity
@@ -156,66 +302,202 @@ func (p *Plane) calculateSpeed(deltaT float64) mathutils.Vector3D {
 	return p.speed.Add(globalAcceleration.MulScalar(deltaT))
 }
 
-func (p *Plane) calculateLift() float64 {
+// calculateLift calculate the lift of the wings and the drag it induces, from the airflow around the plane.
+// The lift is perpendicular to the airflow. The force is expressed in newtons
+func (p *Plane) calculateLift(airDensity float64, wind mathutils.Vector3D) (lift mathutils.Vector3D) {
+
+	localSpeed := p.getLocalAirspeed(wind)
+	// Only the airflow in the plane of symmetry matters
+	airflow := math.Sqrt(localSpeed.Y*localSpeed.Y + localSpeed.Z*localSpeed.Z)
+
+	if airflow == 0 {
+		return lift
+	}
+
+	coefficient := p.liftCoefficient(p.angleOfAttack(localSpeed))
+	dynamicPressure := 0.5 * airDensity * airflow * airflow * p.model.WingArea
+
+	liftForce := dynamicPressure * coefficient
+	inducedDrag := dynamicPressure * p.model.InducedDrag * coefficient * coefficient
+
+	// Lift: perpendicular to the airflow. Induced drag: against the airflow
+	lift.Y = (liftForce*localSpeed.Z - inducedDrag*localSpeed.Y) / airflow
+	lift.Z = (-liftForce*localSpeed.Y - inducedDrag*localSpeed.Z) / airflow
+	return lift
+}
+
+// angleOfAttack returns the angle (radians) between the nose of the plane and the airflow.
+// It's positive when the air comes from below
+func (p *Plane) angleOfAttack(localSpeed mathutils.Vector3D) float64 {
+	return math.Atan2(-localSpeed.Y, localSpeed.Z)
+}
+
+// liftCoefficient returns the lift coefficient of the wings at the angle of attack "alpha".
+// It grows linearly up to the critical angle. Past it, the wing stalls: the lift collapses and fades away.
+func (p *Plane) liftCoefficient(alpha float64) float64 {
+
+	const stallLiftRatio = 0.6 // Part of the lift that remains right after the stall
+
+	critical := p.model.CriticalAngle
+
+	if math.Abs(alpha) <= critical {
+		return p.model.LiftZero + p.model.LiftSlope*alpha
+	}
+
+	peak := p.model.LiftZero + p.model.LiftSlope*math.Copysign(critical, alpha)
+	// No more lift when the airflow is perpendicular to the wings
+	fading := math.Max(0, 1-(math.Abs(alpha)-critical)/(math.Pi/2-critical))
 
-	return p.model.LiftMin + -p.input.Pitch*(p.model.LiftMax-p.model.LiftMin)
+	return peak * stallLiftRatio * fading
 }
 
-// calculateDrag calculate the amount of drag.
+// calculateDrag calculate the amount of drag from the speed of the plane relative to the air.
 // Please note that the force here is expressed in newtons
-func (p *Plane) calculateDrag() (drag mathutils.Vector3D) {
-
-	localSpeed := p.getLocalSpeed()
-	airDensity := getAirDensity(p.location.Y)
-	// We inveres so the forces are applied in the right directions
-	drag.X = -(p.model.DragFactors.X * (localSpeed.X * localSpeed.X) * airDensity)
-	drag.Y = -(p.model.DragFactors.Y * (localSpeed.Y * localSpeed.Y) * airDensity)
-	drag.Z = -(p.model.DragFactors.Z * (localSpeed.Z * localSpeed.Z) * airDensity)
+func (p *Plane) calculateDrag(airDensity float64, wind mathutils.Vector3D) (drag mathutils.Vector3D) {
+
+	localSpeed := p.getLocalAirspeed(wind)
+	// We inverse so the forces are applied against the airflow
+	drag.X = -(p.model.DragFactors.X * (localSpeed.X * math.Abs(localSpeed.X)) * airDensity)
+	drag.Y = -(p.model.DragFactors.Y * (localSpeed.Y * math.Abs(localSpeed.Y)) * airDensity)
+	drag.Z = -(p.model.DragFactors.Z * (localSpeed.Z * math.Abs(localSpeed.Z)) * airDensity)
 	return
 }
 
-func (p *Plane) calculateThrust() float64 {
+// calculateThrust returns the acceleration given by the engine.
+// The engine loses power as the air gets thinner.
+func (p *Plane) calculateThrust(airDensity float64) float64 {
+
+	if p.isOutOfFuel() {
+		return 0
+	}
 
-	return (p.input.Thrust * p.model.MaxThrust) / p.model.Mass
+	thrust := p.engine.power * p.model.MaxThrust
+	if p.engine.afterburner {
+		thrust += p.model.AfterburnerThrust
+	}
+	return (thrust * (airDensity / SeaLevelDensity)) / p.mass()
 }
 
 // CorrectFromCollision update the position of the plane if there is a collision with the terrain
 func (p *Plane) CorrectFromCollision(terrain *Terrain) {
 	const margin = 5
-	triangle := terrain.OverredTriangle(p.location)
-
-	// We are out of bound
-	if math.IsNaN(triangle[0].X) {
-		return
-	}
+	// Out of bound, the ground continues from the edge of the map
+	ground := terrain.Clamp(p.location)
+	triangle := terrain.OverredTriangle(ground)
 
 	// Small optimization
 	if p.location.Y >= mathutils.HighestInTriangle(&triangle)+margin {
 		return
 	}
 	// The real thing
-	h := mathutils.HeightOnTriangle(p.location, &triangle)
+	h := mathutils.HeightOnTriangle(ground, &triangle)
 
 	// We are under the surface
 	if p.location.Y < h+margin {
+		// That's a crash when it goes too fast into the ground, not along it
+		if p.impactSpeed(&triangle) > p.crashSpeed() {
+			p.destroy(PlaneCrashed)
+		}
 		// We go back to the surface
 		p.location.Y = h + margin
 		p.speed.Y = 0
 	}
 }
 
-func (p *Plane) getLocalSpeed() mathutils.Vector3D {
-	p.orientation.Inverse(&p.orientationInverse)
-	return p.speed.MultiplyByMatrix3(&p.orientationInverse)
+// impactSpeed returns the speed of the plane into the ground of the triangle
+func (p *Plane) impactSpeed(triangle *[3]mathutils.Vector3D) float64 {
+	v := triangle[1].Sub(triangle[0])
+	w := triangle[2].Sub(triangle[0])
+	normal := mathutils.CrossProduct(&v, &w)
+
+	// The normal goes up, out of the ground
+	if normal.Y < 0 {
+		normal = normal.MulScalar(-1)
+	}
+	normal = normal.DivScalar(normal.Length())
+	return -mathutils.DotProduct(&p.speed, &normal)
 }
 
-// getAirDensity returns the air density at the current altitude
-func getAirDensity(altitude float64) float64 {
-	// HACK: always returns 1.2
-	return 1.2
+// getLocalAirspeed returns the speed of the plane relative to the air, in local space
+func (p *Plane) getLocalAirspeed(wind mathutils.Vector3D) mathutils.Vector3D {
+	p.orientation.Inverse(&p.orientationInverse)
+	airspeed := p.speed.Sub(wind)
+	return airspeed.MultiplyByMatrix3(&p.orientationInverse)
 }
 
 func (p *Plane) isDead() bool {
 
 	return p.isNoMore
 }
+
+// isHitBy checks if the bullet went through the hit sphere of the plane during its last move.
+// t is when it entered the sphere: 0 at the beginning of the move, 1 at the end.
+// A plane can't be hit by its own bullets
+func (p *Plane) isHitBy(b *Bullet) (t float64, hit bool) {
+	if b.source == p.UID || p.isDead() {
+		return 0, false
+	}
+	return mathutils.SegmentSphereIntersection(b.previous, b.location, p.location, p.hitRadius())
+}
+
+// position returns the location of the plane
+func (p *Plane) position() mathutils.Vector3D {
+	return p.location
+}
+
+// velocity returns the speed of the plane in global space
+func (p *Plane) velocity() mathutils.Vector3D {
+	return p.speed
+}
+
+// hitRadius returns the radius of the sphere that contains the plane
+func (p *Plane) hitRadius() float64 {
+	if p.model.HitRadius > 0 {
+		return p.model.HitRadius
+	}
+	return PlaneHitRadius
+}
+
+// crashSpeed returns the speed into the ground above which the plane crashes
+func (p *Plane) crashSpeed() float64 {
+	if p.model.CrashSpeed > 0 {
+		return p.model.CrashSpeed
+	}
+	return PlaneCrashSpeed
+}
+
+// disarm ignores the weapons and the countermeasures asked by the player
+func (p *Plane) disarm() {
+	p.input.IsFiring = false
+	p.input.IsLaunching = false
+	p.input.IsDeploying = false
+	p.input.IsBombing = false
+}
+
+// takeDamage removes "damage" from the life of the plane.
+// The plane is no more when there is no life left. "cause" is then reported to the players
+func (p *Plane) takeDamage(damage uint8, cause uint8) {
+	if damage >= p.life {
+		p.life = 0
+		p.destroy(cause)
+		return
+	}
+	p.life -= damage
+}
+
+// destroy puts an end to the plane. "cause" is the state reported to the players
+func (p *Plane) destroy(cause uint8) {
+	if p.isNoMore {
+		return
+	}
+	p.isNoMore = true
+	p.cause = cause
+}
+
+// damagePercentage returns the damage taken by the plane in percents (fits in 7 bits)
+func (p *Plane) damagePercentage() uint8 {
+	if p.model.Life == 0 {
+		return 0
+	}
+	return uint8(100 * uint(p.model.Life-p.life) / uint(p.model.Life))
+}
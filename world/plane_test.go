@@ -22,9 +22,13 @@ func dummyPlane(uid uint8) (*Plane, chan *Bullet) {
 			Y: 0.005,
 			Z: 0.05,
 		},
-		LiftMin:      0.0005,
-		LiftMax:      0.0007,
-		DefaultSpeed: 150,
+		WingArea:      20,
+		LiftZero:      0.1,
+		LiftSlope:     5,
+		CriticalAngle: 0.3,
+		InducedDrag:   0.05,
+		DefaultSpeed:  150,
+		Guns:          []GunModel{dummyGunModel()},
 	}
 
 	gun := make(chan *Bullet, 1)
@@ -81,9 +85,93 @@ func TestSnapshot(t *testing.T) {
 	}
 }
 
-func TestGetAirDensity(t *testing.T) {
-	if getAirDensity(500) != 1.2 {
-		t.Fail()
+func TestDragWithWind(t *testing.T) {
+	plane, _ := dummyPlane(3)
+	plane.speed = mathutils.Vector3D{X: 0, Y: 0, Z: 100}
+
+	calm := plane.calculateDrag(1.2, mathutils.Vector3D{})
+	headwind := plane.calculateDrag(1.2, mathutils.Vector3D{X: 0, Y: 0, Z: -20})
+	tailwind := plane.calculateDrag(1.2, mathutils.Vector3D{X: 0, Y: 0, Z: 100})
+	crosswind := plane.calculateDrag(1.2, mathutils.Vector3D{X: 10, Y: 0, Z: 0})
+
+	if calm.Z >= 0 || headwind.Z >= calm.Z {
+		t.Errorf("A headwind should increase the drag: %f / %f", headwind.Z, calm.Z)
+	}
+
+	if tailwind.Z != 0 {
+		t.Errorf("There should be no drag when flying with the air: %f", tailwind.Z)
+	}
+
+	if crosswind.X <= 0 {
+		t.Errorf("The crosswind should push the plane along +X: %f", crosswind.X)
+	}
+}
+
+func TestLift(t *testing.T) {
+	plane, _ := dummyPlane(3)
+	calm := mathutils.Vector3D{}
+
+	plane.speed = mathutils.Vector3D{}
+	if lift := plane.calculateLift(1.2, calm); lift.Y != 0 || lift.Z != 0 {
+		t.Errorf("There should be no lift without speed: %+v", lift)
+	}
+
+	plane.speed = mathutils.Vector3D{X: 0, Y: 0, Z: 100}
+	slow := plane.calculateLift(1.2, calm)
+
+	plane.speed = mathutils.Vector3D{X: 0, Y: 0, Z: 200}
+	fast := plane.calculateLift(1.2, calm)
+
+	if slow.Y <= 0 || !isClose(fast.Y, 4*slow.Y, 1e-6) {
+		t.Errorf("The lift should grow with the square of the speed: %f / %f", slow.Y, fast.Y)
+	}
+
+	if slow.Z >= 0 {
+		t.Errorf("The induced drag should slow the plane down: %f", slow.Z)
+	}
+
+	// The air comes from below: more lift
+	plane.speed = mathutils.Vector3D{X: 0, Y: -10, Z: 100}
+	if lift := plane.calculateLift(1.2, calm); lift.Y <= slow.Y {
+		t.Errorf("The lift should grow with the angle of attack: %f", lift.Y)
+	}
+}
+
+func TestStall(t *testing.T) {
+	plane, _ := dummyPlane(3)
+	critical := plane.model.CriticalAngle
+
+	beforeStall := plane.liftCoefficient(critical)
+	afterStall := plane.liftCoefficient(critical + 0.05)
+
+	if afterStall >= beforeStall {
+		t.Errorf("The lift should collapse after the critical angle: %f / %f", beforeStall, afterStall)
+	}
+
+	if plane.liftCoefficient(math.Pi/2) != 0 {
+		t.Errorf("There should be no lift at 90°: %f", plane.liftCoefficient(math.Pi/2))
+	}
+
+	if plane.liftCoefficient(-critical-0.05) >= 0 {
+		t.Error("The lift should be negative when stalling upside down")
+	}
+}
+
+func TestThrustAtAltitude(t *testing.T) {
+	plane, _ := dummyPlane(3)
+	plane.input.Thrust = 1
+	plane.updateEngine(1)
+	atmosphere := Atmosphere{}.withDefaults()
+
+	low := plane.calculateThrust(atmosphere.Density(0))
+	high := plane.calculateThrust(atmosphere.Density(10000))
+
+	if !isClose(low, plane.model.MaxThrust/plane.model.Mass, 1e-9) {
+		t.Errorf("Full thrust should be available at sea level: %f", low)
+	}
+
+	if high >= low/2 {
+		t.Errorf("The engine should lose power at altitude: %f", high)
 	}
 }
 
@@ -91,12 +179,162 @@ func TestFire(t *testing.T) {
 
 	plane, gun := dummyPlane(3)
 
-	plane.fire()
+	plane.fire(plane.guns[0])
 
 	bullet := <-gun
 
-	if bullet.speed.Y != 600 {
+	// Fired from the nose, with the speed of the plane
+	if !isClose(bullet.speed.Z, 600+150, 1e-9) || bullet.speed.X != 0 || bullet.speed.Y != 0 {
+		t.Errorf("Wrong speed: %+v", bullet.speed)
+	}
+
+	if bullet.timeToLive != defaultBulletLifetime {
+		t.Errorf("The bullet should live %d seconds, not %f", defaultBulletLifetime, bullet.timeToLive)
+	}
+}
+
+func TestFireDispersion(t *testing.T) {
+
+	fire := func() []mathutils.Vector3D {
+		plane, gun := dummyPlane(3)
+		plane.guns[0].model.Dispersion = 0.01
+		plane.speed = mathutils.Vector3D{}
+
+		speeds := []mathutils.Vector3D{}
+		for i := 0; i < 20; i++ {
+			plane.fire(plane.guns[0])
+			speeds = append(speeds, (<-gun).speed)
+		}
+		return speeds
+	}
+
+	first := fire()
+	second := fire()
+
+	spread := false
+	for i, speed := range first {
+		if !isClose(speed.Length(), 600, 1e-9) || speed.Z < 590 {
+			t.Errorf("The bullet %d goes the wrong way: %+v", i, speed)
+		}
+
+		if speed.X != 0 || speed.Y != 0 {
+			spread = true
+		}
+
+		if speed != second[i] {
+			t.Errorf("The dispersion should be the same with the same seed")
+		}
+	}
+
+	if !spread {
+		t.Error("The bullets should be spread")
+	}
+
+}
+
+func TestTakeDamage(t *testing.T) {
+	plane, _ := dummyPlane(3)
+	plane.model.Life = 100
+	plane.life = 100
+
+	plane.takeDamage(30, PlaneDestroyed)
+
+	if plane.life != 70 || plane.isDead() {
+		t.Errorf("life is %d, isNoMore is %t", plane.life, plane.isNoMore)
+	}
+
+	if plane.damagePercentage() != 30 {
+		t.Errorf("damage is %d%%", plane.damagePercentage())
+	}
+
+	plane.takeDamage(200, PlaneDestroyed)
+
+	if plane.life != 0 || !plane.isDead() {
+		t.Errorf("life is %d, isNoMore is %t", plane.life, plane.isNoMore)
+	}
+}
+
+func TestCrash(t *testing.T) {
+	w := getTestWorld()
+	plane, _ := dummyPlane(3)
+	plane.model.CrashSpeed = 50
+
+	// Slow enough to touch the ground
+	plane.location = mathutils.Vector3D{X: 500, Y: 0, Z: 500}
+	plane.speed = mathutils.Vector3D{X: 0, Y: -10, Z: 30}
+	plane.CorrectFromCollision(w.terrain)
+
+	if plane.isDead() {
+		t.Error("The plane should not have crashed")
+	}
+
+	// Fast, but along the ground
+	plane.location.Y = 0
+	plane.speed = mathutils.Vector3D{X: 0, Y: -5, Z: 150}
+	plane.CorrectFromCollision(w.terrain)
+
+	if plane.isDead() {
+		t.Error("The plane should not have crashed while flying along the ground")
+	}
+
+	// Way too fast into the ground
+	plane.location.Y = 0
+	plane.speed = mathutils.Vector3D{X: 0, Y: -120, Z: 150}
+	plane.CorrectFromCollision(w.terrain)
+
+	if !plane.isDead() || plane.cause != PlaneCrashed {
+		t.Error("The plane should have crashed")
+	}
+}
+
+func TestIsHitBy(t *testing.T) {
+	plane, _ := dummyPlane(3)
+	plane.life = 100
+
+	bullet := &Bullet{source: 4, location: plane.location, previous: plane.location, damage: 10}
+
+	if _, hit := plane.isHitBy(bullet); !hit {
+		t.Error("The plane should be hit")
+	}
+
+	// A plane can't shoot itself
+	bullet.source = 3
+	if _, hit := plane.isHitBy(bullet); hit {
+		t.Error("The plane should not be hit by its own bullet")
+	}
+
+	bullet.source = 4
+	bullet.location.Y += PlaneHitRadius * 2
+	bullet.previous.Y += PlaneHitRadius * 2
+	if _, hit := plane.isHitBy(bullet); hit {
+		t.Error("The bullet is too far to hit the plane")
+	}
+}
+
+func TestUpdateGuns(t *testing.T) {
+	plane, gun := dummyPlane(3)
+
+	plane.updateGuns(1)
+
+	if len(gun) != 0 {
+		t.Error("The plane should not fire without input")
+	}
+
+	plane.Write([]byte{0x3, 0, 0, 0, 0, 0x80})
+	plane.updateGuns(0.01)
+
+	if len(gun) != 1 {
+		t.Errorf("%d bullets were fired instead of 1", len(gun))
+	}
+
+	if plane.guns[0].ammo != dummyGunModel().Ammo-1 {
 		t.Fail()
 	}
 
+	snap := make([]byte, PlaneSnapshotSize)
+	plane.Read(snap)
+
+	if snap[1]&0x80 == 0 {
+		t.Error("The firing bit should be set in the snapshot")
+	}
 }
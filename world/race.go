@@ -0,0 +1,288 @@
+package world
+
+import (
+	"encoding/binary"
+	"log"
+	"math"
+	"sort"
+
+	"github.com/eaglesight/eaglesight-server/mathutils"
+)
+
+// ModeAirRace : no weapons, the planes fly through the rings of the scenario, in order
+const ModeAirRace = "airRace"
+
+// raceFinishDelay is the time in seconds the others have to finish once the first plane did
+const raceFinishDelay = 60
+
+// RaceStandingSize : uint8 (uid) + uint8 (laps) + uint8 (next ring) + uint8 (finished) + float32 * 3 (race time, last split, last lap)
+const RaceStandingSize = 1 + 1 + 1 + 1 + (3 * 4)
+
+// Ring is a checkpoint of an air race
+type Ring struct {
+	Name   string             `json:"name"`
+	Center mathutils.Vector3D `json:"center"`
+	Normal mathutils.Vector3D `json:"normal"` // The planes fly through the ring in this direction
+	Radius float64            `json:"radius"`
+}
+
+// validRings returns the rings of the course that can be passed. The others are ignored
+func validRings(rings []Ring) []Ring {
+
+	valid := []Ring{}
+	for _, ring := range rings {
+		if ring.Normal.Length() == 0 || ring.Radius <= 0 {
+			log.Printf("The ring %s has no normal or no radius, it's ignored\n", ring.Name)
+			continue
+		}
+		valid = append(valid, ring)
+	}
+	return valid
+}
+
+// RaceProgress is where a plane is in the race
+type RaceProgress struct {
+	course   []Ring
+	laps     int
+	next     int       // Index of the next ring
+	lap      int       // Laps done
+	time     float64   // Seconds since the start
+	splits   []float64 // Race time at every ring passed
+	lapTimes []float64
+	finished bool
+	passed   bool // A ring was passed since the last report
+}
+
+// NewRaceProgress starts the race on the course for "laps" laps (at least 1)
+func NewRaceProgress(course []Ring, laps int) *RaceProgress {
+	if laps < 1 {
+		laps = 1
+	}
+	return &RaceProgress{course: course, laps: laps}
+}
+
+// tick runs the race clock. It runs even when the plane is no more
+func (r *RaceProgress) tick(deltaT float64) {
+	if !r.finished {
+		r.time += deltaT
+	}
+}
+
+// update checks if the plane passed the next ring while moving from "from" to "to" during the last deltaT
+func (r *RaceProgress) update(from mathutils.Vector3D, to mathutils.Vector3D, deltaT float64) {
+
+	if r.finished || len(r.course) == 0 {
+		return
+	}
+
+	ring := &r.course[r.next]
+
+	// Only in the right direction
+	movement := to.Sub(from)
+	if mathutils.DotProduct(&ring.Normal, &movement) <= 0 {
+		return
+	}
+
+	t, hit := mathutils.SegmentDiscIntersection(from, to, ring.Center, ring.Normal, ring.Radius)
+	if !hit {
+		return
+	}
+
+	// When the plane was exactly in the ring
+	split := r.time - deltaT*(1-t)
+	r.splits = append(r.splits, split)
+	r.passed = true
+	r.next++
+
+	if r.next == len(r.course) {
+		r.next = 0
+		r.lap++
+		r.lapTimes = append(r.lapTimes, split-r.lapStart())
+		r.finished = r.lap == r.laps
+	}
+}
+
+// lapStart returns the race time at the start of the current lap
+func (r *RaceProgress) lapStart() float64 {
+	start := 0.0
+	for _, lap := range r.lapTimes {
+		start += lap
+	}
+	return start
+}
+
+// rings returns the number of rings passed
+func (r *RaceProgress) rings() int {
+	return r.lap*len(r.course) + r.next
+}
+
+// AirRace : the first to finish all the laps wins
+type AirRace struct {
+	noHooks
+	finishers []uint8 // In the order of arrival
+	timeLeft  float64 // Seconds before the race ends, once a plane finished
+}
+
+// Start puts every plane on the starting line
+func (a *AirRace) Start(w *World) {
+
+	a.finishers = []uint8{}
+	a.timeLeft = 0
+	for _, plane := range w.planes {
+		plane.race = NewRaceProgress(w.scenario.Rings, w.scenario.Laps)
+	}
+	w.emit(a.standingsMessage(w))
+}
+
+// Join : a latecomer starts its race
+func (a *AirRace) Join(w *World, plane *Plane) {
+	plane.race = NewRaceProgress(w.scenario.Rings, w.scenario.Laps)
+	w.emit(a.standingsMessage(w))
+}
+
+// Tick runs the race clocks and reports the rings passed
+func (a *AirRace) Tick(w *World, deltaT float64) {
+
+	changed := false
+	finishers := []*Plane{}
+
+	started := len(a.finishers) > 0
+	if started {
+		a.timeLeft -= deltaT
+	}
+
+	for _, plane := range w.sortedPlanes() {
+
+		if plane.race == nil {
+			continue
+		}
+
+		if plane.race.passed {
+			plane.race.passed = false
+			changed = true
+
+			if plane.race.finished {
+				finishers = append(finishers, plane)
+			}
+		}
+		plane.race.tick(deltaT)
+	}
+
+	// Planes that finish during the same tick are ranked by their time
+	sort.SliceStable(finishers, func(i, j int) bool {
+		return last(finishers[i].race.splits) < last(finishers[j].race.splits)
+	})
+	for _, plane := range finishers {
+		a.finishers = append(a.finishers, plane.UID)
+	}
+
+	// The first one is there: the others have a little time left
+	if !started && len(a.finishers) > 0 {
+		a.timeLeft = raceFinishDelay
+	}
+
+	if changed {
+		w.emit(a.standingsMessage(w))
+	}
+}
+
+// Armed : no weapons in a race
+func (a *AirRace) Armed() bool {
+	return false
+}
+
+// CanRespawn : always. The plane keeps its progress
+func (a *AirRace) CanRespawn(w *World, plane *Plane) bool {
+	return true
+}
+
+// Standings returns the rings passed by every plane. The finishers are first, in the order of arrival
+func (a *AirRace) Standings(w *World) map[uint8]int {
+
+	standings := make(map[uint8]int)
+
+	for uid, plane := range w.planes {
+		if plane.race != nil {
+			standings[uid] = plane.race.rings()
+		}
+	}
+
+	for i, uid := range a.finishers {
+		if _, exists := standings[uid]; exists {
+			standings[uid] += len(a.finishers) - i
+		}
+	}
+	return standings
+}
+
+// IsOver returns whether every plane finished the race, or the others ran out of time. The first one wins
+func (a *AirRace) IsOver(w *World) (over bool, winner uint8) {
+
+	if len(w.scenario.Rings) == 0 || len(w.planes) == 0 {
+		return false, NoWinner
+	}
+
+	if len(a.finishers) > 0 && a.timeLeft <= 0 {
+		return true, a.finishers[0]
+	}
+
+	for _, plane := range w.planes {
+		if plane.race == nil || !plane.race.finished {
+			return false, NoWinner
+		}
+	}
+	if len(a.finishers) == 0 {
+		return true, NoWinner
+	}
+	return true, a.finishers[0]
+}
+
+// standingsMessage tells the players where they are in the race, the first one first:
+// 0x11|count|(uid|laps done|next ring|finished|race time|last split|last lap time (float32))...
+func (a *AirRace) standingsMessage(w *World) []byte {
+
+	standings := a.Standings(w)
+
+	racers := []*Plane{}
+	for _, plane := range w.sortedPlanes() {
+		if plane.race != nil {
+			racers = append(racers, plane)
+		}
+	}
+
+	// The farthest first. Between two planes at the same ring, the one that got there first
+	sort.SliceStable(racers, func(i, j int) bool {
+		if standings[racers[i].UID] != standings[racers[j].UID] {
+			return standings[racers[i].UID] > standings[racers[j].UID]
+		}
+		return last(racers[i].race.splits) < last(racers[j].race.splits)
+	})
+
+	message := make([]byte, 2+RaceStandingSize*len(racers))
+	message[0] = 0x11
+	message[1] = uint8(len(racers))
+
+	offset := 2
+	for _, plane := range racers {
+		race := plane.race
+		message[offset] = plane.UID
+		message[offset+1] = uint8(race.lap)
+		message[offset+2] = uint8(race.next)
+		if race.finished {
+			message[offset+3] = 1
+		}
+		binary.BigEndian.PutUint32(message[offset+4:], math.Float32bits(float32(race.time)))
+		binary.BigEndian.PutUint32(message[offset+8:], math.Float32bits(float32(last(race.splits))))
+		binary.BigEndian.PutUint32(message[offset+12:], math.Float32bits(float32(last(race.lapTimes))))
+		offset += RaceStandingSize
+	}
+	return message
+}
+
+// last returns the last time of the list, 0 if it's empty
+func last(times []float64) float64 {
+	if len(times) == 0 {
+		return 0
+	}
+	return times[len(times)-1]
+}
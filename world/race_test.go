@@ -0,0 +1,179 @@
+package world
+
+import (
+	"testing"
+
+	"github.com/eaglesight/eaglesight-server/mathutils"
+)
+
+// raceCourse is 2 rings along +Z, 1000 units apart
+func raceCourse() []Ring {
+	forward := mathutils.Vector3D{X: 0, Y: 0, Z: 1}
+	return []Ring{
+		{Name: "start", Center: mathutils.Vector3D{X: 1000, Y: 1500, Z: 1000}, Normal: forward, Radius: 20},
+		{Name: "finish", Center: mathutils.Vector3D{X: 1000, Y: 1500, Z: 2000}, Normal: forward, Radius: 20},
+	}
+}
+
+// raceRules is an air race between 2 planes
+var raceRules = Rules{Mode: ModeAirRace, Match: MatchRules{MinPlayers: 2}}
+
+// flyThrough moves the plane through the ring "i" of the course
+func flyThrough(plane *Plane, i int) {
+	ring := plane.race.course[i]
+	before := ring.Center.Add(mathutils.Vector3D{X: 0, Y: 0, Z: -5})
+	after := ring.Center.Add(mathutils.Vector3D{X: 0, Y: 0, Z: 5})
+	plane.race.update(before, after, 0.1)
+}
+
+func TestRingPassage(t *testing.T) {
+	race := NewRaceProgress(raceCourse(), 1)
+	race.tick(10)
+
+	// The wrong way
+	race.update(mathutils.Vector3D{X: 1000, Y: 1500, Z: 1010}, mathutils.Vector3D{X: 1000, Y: 1500, Z: 990}, 0.1)
+	// Not the next ring
+	race.update(mathutils.Vector3D{X: 1000, Y: 1500, Z: 1990}, mathutils.Vector3D{X: 1000, Y: 1500, Z: 2010}, 0.1)
+
+	if race.next != 0 || race.passed {
+		t.Fatal("No ring should be passed")
+	}
+
+	// A quarter of the way
+	race.update(mathutils.Vector3D{X: 1000, Y: 1500, Z: 995}, mathutils.Vector3D{X: 1000, Y: 1500, Z: 1015}, 0.1)
+
+	if race.next != 1 || !race.passed || !isClose(race.splits[0], 10-0.075, 1e-9) {
+		t.Errorf("The first ring should be passed at 9.925s: %+v", race)
+	}
+}
+
+func TestPlanePassesRing(t *testing.T) {
+	plane, _ := dummyPlane(1)
+	plane.respawn(SpawnPoint{Location: mathutils.Vector3D{X: 1000, Y: 1500, Z: 990}, Speed: 150})
+	plane.race = NewRaceProgress(raceCourse(), 1)
+
+	w := getTestWorld()
+	for i := 0; i < 10; i++ {
+		plane.Update(0.01, w.terrain, w.atmosphere)
+	}
+
+	if plane.race.next != 1 {
+		t.Errorf("The plane should fly through the first ring: %+v", plane.location)
+	}
+}
+
+func TestAirRace(t *testing.T) {
+	w := newTestWorld(Scenario{Rings: raceCourse(), Laps: 2}, raceRules, 0, 0)
+	first, second := w.planes[1], w.planes[2]
+
+	if first.race == nil || len(eventsWithOpcode(w, 0x11)) != 1 {
+		t.Fatal("The race should start")
+	}
+
+	// No weapons
+	w.applyInput(&PlayerInput{UID: 1, Data: []byte{0x3, 0, 0, 0, 0, 0xF0}})
+	if first.input.IsFiring || first.input.IsLaunching || first.input.IsDeploying || first.input.IsBombing {
+		t.Errorf("The planes can't use their weapons during a race: %+v", first.input)
+	}
+	w.updateMatch(0.1)
+
+	// The plane 1 does the 2 laps
+	for lap := 0; lap < 2; lap++ {
+		flyThrough(first, 0)
+		flyThrough(first, 1)
+	}
+	flyThrough(second, 0)
+	w.updateMatch(0.1)
+
+	if !first.race.finished || len(first.race.lapTimes) != 2 || len(first.race.splits) != 4 {
+		t.Fatalf("The plane 1 should finish: %+v", first.race)
+	}
+
+	standings := eventsWithOpcode(w, 0x11)
+	last := standings[len(standings)-1]
+	if last[1] != 2 || last[2] != 1 || last[2+3] != 1 || last[2+RaceStandingSize] != 2 || last[2+RaceStandingSize+2] != 1 {
+		t.Errorf("The plane 1 should lead the standings: %v", last)
+	}
+
+	if w.phase != MatchLive {
+		t.Fatal("The race goes on until everybody is there")
+	}
+
+	flyThrough(second, 1)
+	for i := 0; i < 2; i++ {
+		flyThrough(second, i)
+	}
+	w.updateMatch(0.1)
+
+	if w.phase != MatchEnded || w.winner != 1 {
+		t.Errorf("The plane 1 should win: phase %d, winner %d", w.phase, w.winner)
+	}
+}
+
+func TestRaceWithoutTurrets(t *testing.T) {
+	w := newTestWorld(Scenario{Rings: raceCourse(), Laps: 2}, raceRules, 0, 0)
+	gun := dummyGunModel()
+	gun.Ammo = 1000
+	w.scenario.Targets = []GroundTargetModel{
+		{Name: "flak", Kind: "aa", Life: 50, HitRadius: 5, Turret: &TurretModel{Range: 1e6, TurnRate: 10, Gun: gun}},
+	}
+	w.placeTargets()
+
+	for i := 0; i < 100; i++ {
+		w.updateNPCs(0.01)
+	}
+	w.collectBullets()
+
+	if len(w.bullets) != 0 {
+		t.Error("The turrets don't fire during a race")
+	}
+}
+
+func TestFinishersSameTick(t *testing.T) {
+	w := newTestWorld(Scenario{Rings: raceCourse(), Laps: 2}, raceRules, 0, 0)
+	first, second := w.planes[1], w.planes[2]
+	first.race.laps, second.race.laps = 1, 1
+
+	flyThrough(first, 0)
+	flyThrough(second, 0)
+
+	// Both finish during the same tick, the plane 2 earlier in the tick
+	ring := first.race.course[1]
+	first.race.update(ring.Center.Add(mathutils.Vector3D{X: 0, Y: 0, Z: -9}), ring.Center.Add(mathutils.Vector3D{X: 0, Y: 0, Z: 1}), 0.1)
+	second.race.update(ring.Center.Add(mathutils.Vector3D{X: 0, Y: 0, Z: -1}), ring.Center.Add(mathutils.Vector3D{X: 0, Y: 0, Z: 9}), 0.1)
+	w.updateMatch(0.1)
+
+	if w.phase != MatchEnded || w.winner != 2 {
+		t.Errorf("The plane 2 should win: phase %d, winner %d", w.phase, w.winner)
+	}
+}
+
+func TestRaceFinishDelay(t *testing.T) {
+	w := newTestWorld(Scenario{Rings: raceCourse()}, raceRules, 0, 0)
+
+	// The plane 2 doesn't race
+	flyThrough(w.planes[1], 0)
+	flyThrough(w.planes[1], 1)
+	w.updateMatch(0.1)
+
+	if w.phase != MatchLive {
+		t.Fatal("The others have some time to finish")
+	}
+
+	w.updateMatch(raceFinishDelay)
+	if w.phase != MatchEnded || w.winner != 1 {
+		t.Errorf("The race should end after the delay: phase %d, winner %d", w.phase, w.winner)
+	}
+}
+
+func TestInvalidRings(t *testing.T) {
+	course := raceCourse()
+	course[0].Normal = mathutils.Vector3D{}
+	course = append(course, Ring{Name: "tiny", Center: mathutils.Vector3D{X: 1000, Y: 1500, Z: 3000}, Normal: mathutils.Vector3D{X: 0, Y: 0, Z: 1}})
+
+	w := newTestWorld(Scenario{Rings: course}, raceRules)
+
+	if len(w.scenario.Rings) != 1 || w.scenario.Rings[0].Name != "finish" {
+		t.Errorf("The rings that can't be passed should be ignored: %+v", w.scenario.Rings)
+	}
+}
@@ -0,0 +1,49 @@
+package world
+
+import (
+	"log"
+)
+
+// Rules are the gameplay settings of a world
+type Rules struct {
+	RespawnDelay    float64 `json:"respawnDelay"`    // Seconds before a plane that is no more respawns
+	CollisionDamage uint8   `json:"collisionDamage"` // Damage taken by both planes in a mid-air collision. 0 destroys them
+	// Seconds a plane can stay out of the battle area. 0 means defaultOutOfBoundsTimeout
+	OutOfBoundsTimeout float64 `json:"outOfBoundsTimeout"`
+	// Damage taken every second by a plane out of the battle area after the timeout. 0 destroys it
+	OutOfBoundsDamage uint8 `json:"outOfBoundsDamage"`
+	// Seed of the random events (decoys...), so a game can be replayed
+	Seed int64 `json:"seed"`
+	// Damage dealt to the teammates: FriendlyFireFull (default), FriendlyFireReduced or FriendlyFireOff
+	FriendlyFire string `json:"friendlyFire"`
+	// Ratio of the damage dealt to the teammates when the friendly fire is reduced. 0 means defaultFriendlyFireRatio
+	FriendlyFireRatio float64 `json:"friendlyFireRatio"`
+	// Game mode: ModeDeathmatch (default), ModeTeamDeathmatch, ModeLastManStanding or ModeAirRace
+	Mode string `json:"mode"`
+	// Phases of the match. By default, it starts right away and never ends
+	Match MatchRules `json:"match"`
+}
+
+// withDefaults returns the rules with the default friendly fire. An unknown friendly fire is full.
+// A last man standing needs at least 2 players to start
+func (r Rules) withDefaults() Rules {
+
+	switch r.FriendlyFire {
+	case FriendlyFireFull, FriendlyFireReduced, FriendlyFireOff:
+	case "":
+		r.FriendlyFire = FriendlyFireFull
+	default:
+		log.Printf("Unknown friendly fire %q, using %s\n", r.FriendlyFire, FriendlyFireFull)
+		r.FriendlyFire = FriendlyFireFull
+	}
+
+	if r.FriendlyFireRatio == 0 {
+		r.FriendlyFireRatio = defaultFriendlyFireRatio
+	}
+
+	// Otherwise, it starts without opponents and the latecomers can't respawn until it ends
+	if r.Mode == ModeLastManStanding && r.Match.MinPlayers < 2 {
+		r.Match.MinPlayers = 2
+	}
+	return r
+}
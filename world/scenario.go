@@ -0,0 +1,105 @@
+package world
+
+import (
+	"math"
+
+	"github.com/eaglesight/eaglesight-server/mathutils"
+)
+
+// spawnClearance is the distance under which a plane makes a spawn point busy
+const spawnClearance = 200
+
+// SpawnPoint is a place where the planes can spawn
+type SpawnPoint struct {
+	Name     string             `json:"name"`
+	Location mathutils.Vector3D `json:"location"`
+	Heading  float64            `json:"heading"` // Radians around the Y axis. 0 is toward +Z
+	Speed    float64            `json:"speed"`   // Initial speed. The plane's default speed is used when it's 0
+	Team     uint8              `json:"team"`    // Only this team can spawn here. 0 means every team
+}
+
+// Scenario describes what the map contains
+type Scenario struct {
+	SpawnPoints []SpawnPoint        `json:"spawnPoints"`
+	Atmosphere  Atmosphere          `json:"atmosphere"`
+	Bounds      Bounds              `json:"bounds"` // The whole terrain when it's not set
+	Targets     []GroundTargetModel `json:"targets"`
+	Rings       []Ring              `json:"rings"` // Course of the air race, in order
+	Laps        int                 `json:"laps"`  // Laps of the air race. 0 means 1
+}
+
+// defaultSpawnPoint is used when the scenario has no spawn point
+var defaultSpawnPoint = SpawnPoint{
+	Name:     "default",
+	Location: mathutils.Vector3D{X: 0, Y: 1500, Z: 0},
+}
+
+// pickSpawnPoint returns the spawn point of "team" that is the farthest from the other planes.
+// The first free spawn point (no plane closer than spawnClearance) is returned right away.
+// When they are all busy, the plane is moved aside, in the battle area, so it doesn't spawn into another one.
+func (w *World) pickSpawnPoint(team uint8) SpawnPoint {
+
+	const maxShifts = 16
+
+	best := defaultSpawnPoint
+	bestDistance := -1.0
+
+	for _, point := range w.scenario.SpawnPoints {
+
+		if point.Team != 0 && point.Team != team {
+			continue
+		}
+
+		distance := w.distanceToClosestPlane(point.Location)
+
+		if distance >= spawnClearance {
+			return point
+		}
+
+		if distance > bestDistance {
+			best = point
+			bestDistance = distance
+		}
+	}
+
+	// No spawn point for this team
+	if bestDistance < 0 {
+		bestDistance = w.distanceToClosestPlane(best.Location)
+	}
+
+	// Toward the middle of the battle area
+	shift := float64(spawnClearance)
+	if best.Location.X > (w.bounds.Min.X+w.bounds.Max.X)/2 {
+		shift = -shift
+	}
+
+	for i := 0; i < maxShifts && bestDistance < spawnClearance; i++ {
+		best.Location.X += shift
+		best.Location = w.bounds.clamp(best.Location)
+
+		// Not in the ground
+		if w.terrain != nil {
+			best.Location.Y = math.Max(best.Location.Y, w.terrain.HeightAt(best.Location)+spawnClearance)
+		}
+		bestDistance = w.distanceToClosestPlane(best.Location)
+	}
+	return best
+}
+
+// distanceToClosestPlane returns the distance between "location" and the closest living plane
+func (w *World) distanceToClosestPlane(location mathutils.Vector3D) float64 {
+
+	closest := math.Inf(1)
+
+	for _, plane := range w.planes {
+
+		if plane.isDead() {
+			continue
+		}
+
+		if d := mathutils.Distance(plane.location, location); d < closest {
+			closest = d
+		}
+	}
+	return closest
+}
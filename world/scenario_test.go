@@ -0,0 +1,100 @@
+package world
+
+import (
+	"testing"
+
+	"github.com/eaglesight/eaglesight-server/mathutils"
+)
+
+func dummyScenario() Scenario {
+	return Scenario{
+		SpawnPoints: []SpawnPoint{
+			{Name: "north", Location: mathutils.Vector3D{X: 0, Y: 1000, Z: 0}},
+			{Name: "south", Location: mathutils.Vector3D{X: 0, Y: 1000, Z: 2000}, Heading: 3.14159265358979},
+			{Name: "blue", Location: mathutils.Vector3D{X: 2000, Y: 1000, Z: 0}, Team: 2},
+		},
+	}
+}
+
+func TestPickSpawnPoint(t *testing.T) {
+	w := getTestWorld()
+	w.scenario = dummyScenario()
+
+	if point := w.pickSpawnPoint(1); point.Name != "north" {
+		t.Errorf("The first spawn point should be picked, not %s", point.Name)
+	}
+
+	w.addPlane(1, 0, PlaneModel{}, w.gun)
+
+	if w.planes[1].location != w.scenario.SpawnPoints[0].Location {
+		t.Errorf("The plane should be at the first spawn point: %+v", w.planes[1].location)
+	}
+
+	if point := w.pickSpawnPoint(1); point.Name != "south" {
+		t.Errorf("The free spawn point should be picked, not %s", point.Name)
+	}
+
+	w.addPlane(2, 0, PlaneModel{}, w.gun)
+
+	// Everything is busy for team 1: the farthest one is picked
+	w.planes[1].location.Z = 150
+	w.planes[2].location.Z = 1950
+	if point := w.pickSpawnPoint(1); point.Name != "north" {
+		t.Errorf("The farthest spawn point should be picked, not %s", point.Name)
+	}
+
+	// Team 2 has its own spawn point
+	if point := w.pickSpawnPoint(2); point.Name != "blue" {
+		t.Errorf("The team's spawn point should be picked, not %s", point.Name)
+	}
+}
+
+func TestJoinTeamSpawnPoint(t *testing.T) {
+	w := getTestWorld()
+	w.scenario = dummyScenario()
+	w.scenario.SpawnPoints[0].Team = 1
+	w.scenario.SpawnPoints[1].Team = 1
+
+	// The way the server adds the players
+	w.Join(1, 2, PlaneModel{})
+	w.arrive(<-w.join)
+
+	if w.planes[1].location != w.scenario.SpawnPoints[2].Location {
+		t.Errorf("The plane should spawn at its team's spawn point: %+v", w.planes[1].location)
+	}
+}
+
+func TestPickSpawnPointWithoutScenario(t *testing.T) {
+	w := getTestWorld()
+
+	if point := w.pickSpawnPoint(0); point.Location != defaultSpawnPoint.Location {
+		t.Errorf("The default spawn point should be picked, not %+v", point)
+	}
+
+	w.addPlane(1, 0, PlaneModel{}, w.gun)
+	w.addPlane(2, 0, PlaneModel{}, w.gun)
+
+	if d := mathutils.Distance(w.planes[1].location, w.planes[2].location); d < spawnClearance {
+		t.Errorf("The planes should not spawn into each other: %f", d)
+	}
+}
+
+func TestSpawnAsideInBounds(t *testing.T) {
+	w := newTestWorld(Scenario{
+		SpawnPoints: []SpawnPoint{{Name: "east", Location: mathutils.Vector3D{X: 4900, Y: 1500, Z: 2500}}},
+		Bounds:      Bounds{Max: mathutils.Vector3D{X: 5000, Y: 8000, Z: 5000}},
+	}, Rules{RespawnDelay: 1})
+
+	for uid := uint8(1); uid <= 3; uid++ {
+		w.addPlane(uid, 0, PlaneModel{}, w.gun)
+
+		location := w.planes[uid].location
+		if w.bounds.zoneOf(location) == ZoneOutside || location.Y < w.terrain.HeightAt(location) {
+			t.Errorf("The plane %d should spawn in the battle area: %+v", uid, location)
+		}
+	}
+
+	if d := mathutils.Distance(w.planes[2].location, w.planes[3].location); d < spawnClearance {
+		t.Errorf("The planes should not spawn into each other: %f", d)
+	}
+}
@@ -0,0 +1,186 @@
+package world
+
+import (
+	"sort"
+	"sync"
+)
+
+// assistWindow is how long (seconds) a hit counts toward a kill or an assist
+const assistWindow = 15
+
+// Score is what a player did during the match
+type Score struct {
+	Team      uint8
+	Kills     uint16
+	Deaths    uint16
+	Assists   uint16
+	TeamKills uint16 // Teammates destroyed. They are not counted in the kills
+	Targets   uint16 // Ground targets destroyed
+	Damage    uint32 // Damage dealt to the enemies
+}
+
+// damageRecord is a hit taken by a plane
+type damageRecord struct {
+	attacker uint8
+	damage   uint8
+	tick     uint64
+}
+
+// Scoreboard keeps the scores of the match. The world writes it, the game loop reads it
+type Scoreboard struct {
+	sync.Mutex
+	scores map[uint8]*Score
+}
+
+// NewScoreboard returns an empty scoreboard
+func NewScoreboard() *Scoreboard {
+	return &Scoreboard{scores: make(map[uint8]*Score)}
+}
+
+// Scores returns a copy of the scores of every player
+func (s *Scoreboard) Scores() map[uint8]Score {
+	s.Lock()
+	defer s.Unlock()
+
+	scores := make(map[uint8]Score, len(s.scores))
+	for uid, score := range s.scores {
+		scores[uid] = *score
+	}
+	return scores
+}
+
+// TeamScores returns the kills of every team, minus the teammates they destroyed
+func (s *Scoreboard) TeamScores() map[uint8]int {
+	s.Lock()
+	defer s.Unlock()
+
+	teams := make(map[uint8]int)
+	for _, score := range s.scores {
+		if score.Team != 0 {
+			teams[score.Team] += int(score.Kills) - int(score.TeamKills)
+		}
+	}
+	return teams
+}
+
+// reset clears the scores for a new match
+func (s *Scoreboard) reset() {
+	s.Lock()
+	defer s.Unlock()
+
+	s.scores = make(map[uint8]*Score)
+}
+
+// update changes the score of the player "uid" while the scoreboard is locked
+func (s *Scoreboard) update(uid uint8, change func(score *Score)) {
+	s.Lock()
+	defer s.Unlock()
+
+	if score, exists := s.scores[uid]; exists {
+		change(score)
+	}
+}
+
+// join adds the player in the scoreboard. A player that comes back keeps its score
+func (s *Scoreboard) join(uid uint8, team uint8) {
+	s.Lock()
+	defer s.Unlock()
+
+	if _, exists := s.scores[uid]; !exists {
+		s.scores[uid] = &Score{}
+	}
+	s.scores[uid].Team = team
+}
+
+// Scoreboard returns the scoreboard of the match. It's safe to read it from another goroutine
+func (w *World) Scoreboard() *Scoreboard {
+	return w.scoreboard
+}
+
+// recordDamage writes down that "attacker" hit the plane
+func (w *World) recordDamage(attacker uint8, plane *Plane, damage uint8) {
+
+	plane.damageLog = append(plane.damageLog, damageRecord{attacker: attacker, damage: damage, tick: w.tick})
+
+	if attacker != plane.UID && w.isEnemy(attacker, plane.team) {
+		w.scoreboard.update(attacker, func(score *Score) { score.Damage += uint32(damage) })
+	}
+}
+
+// isEnemy returns whether the plane "uid" is an enemy of the team
+func (w *World) isEnemy(uid uint8, team uint8) bool {
+	return team == 0 || w.teamOf(uid) != team
+}
+
+// credit gives the kill and the assists for the destruction of the plane, and tells the players.
+// The last one who hit the plane recently gets the kill, even if it crashed
+func (w *World) credit(plane *Plane) {
+
+	window := uint64(assistWindow / w.timeStep)
+	since := uint64(0)
+	if w.tick > window {
+		since = w.tick - window
+	}
+
+	// Nobody: the plane killed itself
+	killer := plane.UID
+	damages := make(map[uint8]int)
+
+	for _, record := range plane.damageLog {
+		if record.tick < since || record.attacker == plane.UID {
+			continue
+		}
+		killer = record.attacker
+		damages[record.attacker] += int(record.damage)
+	}
+	delete(damages, killer)
+
+	assists := []uint8{}
+	for attacker := range damages {
+		if w.isEnemy(attacker, plane.team) {
+			assists = append(assists, attacker)
+		}
+	}
+	sort.Slice(assists, func(i, j int) bool { return assists[i] < assists[j] })
+
+	w.scoreboard.update(plane.UID, func(score *Score) { score.Deaths++ })
+
+	if killer != plane.UID {
+		w.scoreboard.update(killer, func(score *Score) {
+			if w.isEnemy(killer, plane.team) {
+				score.Kills++
+			} else {
+				score.TeamKills++
+			}
+		})
+	}
+
+	for _, uid := range assists {
+		w.scoreboard.update(uid, func(score *Score) { score.Assists++ })
+	}
+
+	w.emit(killMessage(plane.UID, killer, plane.cause, assists))
+	w.mode.PlaneDestroyed(w, plane, killer)
+}
+
+// creditTarget counts a ground target destroyed by the plane "uid"
+func (w *World) creditTarget(uid uint8, target damageable) {
+	if w.isEnemy(uid, targetTeam(target)) {
+		w.scoreboard.update(uid, func(score *Score) { score.Targets++ })
+	}
+}
+
+// killMessage is the kill feed: 0xE|victim uid|killer uid|cause|assists count|assists uids...
+// The killer is the victim itself when nobody destroyed it, and NPCSource for the NPCs
+func killMessage(victim uint8, killer uint8, cause uint8, assists []uint8) []byte {
+
+	message := make([]byte, 5+len(assists))
+	message[0] = 0xE
+	message[1] = victim
+	message[2] = killer
+	message[3] = cause
+	message[4] = uint8(len(assists))
+	copy(message[5:], assists)
+
+	return message
+}
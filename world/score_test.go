@@ -0,0 +1,101 @@
+package world
+
+import (
+	"testing"
+)
+
+func TestKillAndAssist(t *testing.T) {
+	w := newTestWorld(Scenario{}, Rules{FriendlyFire: FriendlyFireFull}, 1, 1, 2)
+	w.addPlane(4, 2, PlaneModel{Life: 100}, w.gun)
+	victim := w.planes[3]
+
+	w.hurt(2, victim, 30)
+	w.tick += 100
+	w.hurt(1, victim, 80)
+	w.detectDeaths()
+
+	scores := w.Scoreboard().Scores()
+	if scores[1].Kills != 1 || scores[2].Assists != 1 || scores[3].Deaths != 1 || scores[1].Damage != 70 {
+		t.Errorf("Wrong scores: %+v", scores)
+	}
+
+	kills := eventsWithOpcode(w, 0xE)
+	if len(kills) != 1 || kills[0][1] != 3 || kills[0][2] != 1 || kills[0][3] != PlaneDestroyed || kills[0][4] != 1 || kills[0][5] != 2 {
+		t.Errorf("Wrong kill feed: %v", kills)
+	}
+
+	if teams := w.Scoreboard().TeamScores(); teams[1] != 1 || teams[2] != 0 {
+		t.Errorf("Wrong team scores: %v", teams)
+	}
+
+	// The slate is clean after the respawn
+	victim.respawn(defaultSpawnPoint)
+	if len(victim.damageLog) != 0 {
+		t.Error("The damage log should be reset")
+	}
+}
+
+func TestOldHitsDontCount(t *testing.T) {
+	w := newTestWorld(Scenario{}, Rules{FriendlyFire: FriendlyFireFull}, 1, 1, 2)
+	victim := w.planes[3]
+
+	w.hurt(1, victim, 50)
+	w.tick += uint64(assistWindow/w.timeStep) + 1
+
+	// Crashes long after the hit
+	victim.destroy(PlaneCrashed)
+	w.detectDeaths()
+
+	scores := w.Scoreboard().Scores()
+	if scores[1].Kills != 0 || scores[3].Deaths != 1 {
+		t.Errorf("Wrong scores: %+v", scores)
+	}
+
+	kills := eventsWithOpcode(w, 0xE)
+	if len(kills) != 1 || kills[0][2] != 3 || kills[0][3] != PlaneCrashed {
+		t.Errorf("The plane should have killed itself: %v", kills)
+	}
+}
+
+func TestTeamKill(t *testing.T) {
+	w := newTestWorld(Scenario{}, Rules{FriendlyFire: FriendlyFireFull}, 1, 1, 2)
+
+	w.hurt(1, w.planes[2], 200)
+	w.detectDeaths()
+
+	scores := w.Scoreboard().Scores()
+	if scores[1].Kills != 0 || scores[1].TeamKills != 1 || scores[1].Damage != 0 {
+		t.Errorf("A teammate doesn't count as a kill: %+v", scores[1])
+	}
+
+	if teams := w.Scoreboard().TeamScores(); teams[1] != -1 {
+		t.Errorf("The team should lose a point: %v", teams)
+	}
+}
+
+func TestScoreSurvivesLeaving(t *testing.T) {
+	w := newTestWorld(Scenario{}, Rules{FriendlyFire: FriendlyFireFull}, 1, 1, 2)
+
+	w.hurt(1, w.planes[3], 200)
+	w.detectDeaths()
+
+	w.removePlane(1)
+	w.addPlane(1, 1, PlaneModel{Life: 100}, w.gun)
+
+	if w.Scoreboard().Scores()[1].Kills != 1 {
+		t.Error("The player should keep its score")
+	}
+}
+
+func TestDestroyTargetScore(t *testing.T) {
+	w := targetWorld()
+	w.addPlane(1, 0, PlaneModel{Life: 100}, w.gun)
+	truck := w.targets[1]
+
+	w.hurt(1, truck, 255)
+	w.hurt(1, truck, 255)
+
+	if score := w.Scoreboard().Scores()[1]; score.Targets != 1 {
+		t.Errorf("The truck should count once: %+v", score)
+	}
+}
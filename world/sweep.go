@@ -0,0 +1,61 @@
+package world
+
+import (
+	"encoding/binary"
+	"math"
+
+	"github.com/eaglesight/eaglesight-server/mathutils"
+)
+
+// impact describes where and when a projectile hit something
+type impact struct {
+	location mathutils.Vector3D
+	normal   mathutils.Vector3D // Normal of the surface. Only set for the terrain
+	time     float64            // Seconds since the beginning of the tick
+	target   damageable         // What was hit. nil when it's the terrain
+}
+
+// sweepBullet tests the whole move of the bullet during the last tick (not only where it ended),
+// so fast bullets can't go through planes or the terrain. The first impact is returned.
+func (w *World) sweepBullet(bullet *Bullet, deltaT float64) (first impact, hit bool) {
+
+	earliest := math.Inf(1)
+
+	for _, entity := range w.grid.alongSegment(bullet.previous, bullet.location, 0) {
+
+		target := entity.(damageable)
+
+		if t, touched := target.isHitBy(bullet); touched && t < earliest {
+			earliest = t
+			first.target = target
+			hit = true
+		}
+	}
+
+	if w.terrain != nil {
+		if t, normal, touched := w.terrain.SegmentIntersection(bullet.previous, bullet.location); touched && t < earliest {
+			earliest = t
+			first.target = nil
+			first.normal = normal
+			hit = true
+		}
+	}
+
+	if hit {
+		first.location = mathutils.Lerp(bullet.previous, bullet.location, earliest)
+		first.time = earliest * deltaT
+	}
+	return first, hit
+}
+
+// impactMessage tells the players where a bullet hit the ground, so they can show it:
+// 0x9|id of the bullet (uint16)|location (float32 * 3)|normal of the surface (float32 * 3)
+func impactMessage(bullet *Bullet, location mathutils.Vector3D, normal mathutils.Vector3D) []byte {
+
+	message := make([]byte, 1+2+3*4+3*4)
+	message[0] = 0x9 // Impact
+	binary.BigEndian.PutUint16(message[1:], bullet.id)
+	putVector3D(message[3:], location)
+	putVector3D(message[15:], normal)
+	return message
+}
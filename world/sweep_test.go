@@ -0,0 +1,99 @@
+package world
+
+import (
+	"testing"
+
+	"github.com/eaglesight/eaglesight-server/mathutils"
+)
+
+func TestSweepThroughPlane(t *testing.T) {
+	w := getTestWorld()
+	w.addPlane(1, 0, PlaneModel{Life: 100, HitRadius: 5}, w.gun)
+	plane := w.planes[1]
+	w.indexEntities()
+
+	// The bullet goes through the plane during the tick: a point test would miss it
+	bullet := &Bullet{
+		source:   2,
+		previous: plane.location.Sub(mathutils.Vector3D{X: 0, Y: 0, Z: 50}),
+		location: plane.location.Add(mathutils.Vector3D{X: 0, Y: 0, Z: 50}),
+	}
+
+	impact, hit := w.sweepBullet(bullet, 0.01)
+
+	if !hit || impact.target != plane {
+		t.Fatal("The bullet should hit the plane")
+	}
+
+	if !isClose(impact.location.Z, plane.location.Z-5, 1e-6) || !isClose(impact.time, 0.0045, 1e-9) {
+		t.Errorf("Wrong impact: %+v", impact)
+	}
+}
+
+func TestSweepIntoTerrain(t *testing.T) {
+	w := getTestWorld()
+
+	ground := mathutils.Vector3D{X: 1010, Y: 0, Z: 1010}
+	triangle := w.terrain.OverredTriangle(ground)
+	ground.Y = mathutils.HeightOnTriangle(ground, &triangle)
+
+	bullet := &Bullet{
+		previous: ground.Add(mathutils.Vector3D{X: 0, Y: 3, Z: 0}),
+		location: ground.Sub(mathutils.Vector3D{X: 0, Y: 1, Z: 0}),
+	}
+
+	impact, hit := w.sweepBullet(bullet, 0.01)
+
+	if !hit || impact.target != nil {
+		t.Fatal("The bullet should hit the terrain")
+	}
+
+	if !isClose(impact.location.Y, ground.Y, 1e-6) {
+		t.Errorf("The impact should be on the ground (%f), not at %f", ground.Y, impact.location.Y)
+	}
+
+	if impact.normal.Y <= 0 {
+		t.Errorf("The normal should point to the sky: %+v", impact.normal)
+	}
+}
+
+func TestTerrainImpactEvent(t *testing.T) {
+	w := getTestWorld()
+
+	ground := mathutils.Vector3D{X: 1010, Y: 0, Z: 1010}
+	triangle := w.terrain.OverredTriangle(ground)
+	ground.Y = mathutils.HeightOnTriangle(ground, &triangle)
+
+	w.addBullet(&Bullet{
+		previous: ground.Add(mathutils.Vector3D{X: 0, Y: 3, Z: 0}),
+		location: ground.Sub(mathutils.Vector3D{X: 0, Y: 1, Z: 0}),
+	})
+
+	w.indexEntities()
+	w.detectHits(0.01)
+
+	if len(w.bullets) != 0 {
+		t.Error("The bullet should be removed")
+	}
+
+	impacts := eventsWithOpcode(w, 0x9)
+	if len(impacts) != 1 || len(impacts[0]) != 1+2+3*4+3*4 {
+		t.Errorf("The impact should be reported: %v", impacts)
+	}
+}
+
+func TestSweepMiss(t *testing.T) {
+	w := getTestWorld()
+	w.addPlane(1, 0, PlaneModel{Life: 100}, w.gun)
+	w.indexEntities()
+
+	bullet := &Bullet{
+		source:   2,
+		previous: mathutils.Vector3D{X: 1000, Y: 2000, Z: 1000},
+		location: mathutils.Vector3D{X: 1006, Y: 2000, Z: 1000},
+	}
+
+	if _, hit := w.sweepBullet(bullet, 0.01); hit {
+		t.Error("The bullet should not hit anything")
+	}
+}
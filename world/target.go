@@ -0,0 +1,169 @@
+package world
+
+import (
+	"log"
+	"math"
+
+	"github.com/eaglesight/eaglesight-server/mathutils"
+)
+
+// Kinds of ground targets
+const (
+	// TargetBuilding doesn't do anything but it's worth destroying
+	TargetBuilding = 0x1
+	// TargetAA is an anti-aircraft site
+	TargetAA = 0x2
+	// TargetVehicle is a vehicle parked on the ground
+	TargetVehicle = 0x3
+)
+
+// MaxTargets is the number of ground targets a scenario can have: their ids and their count are uint8
+const MaxTargets = 255
+
+// targetKinds maps the kinds of the scenario to the kinds sent to the players
+var targetKinds = map[string]uint8{
+	"building": TargetBuilding,
+	"aa":       TargetAA,
+	"vehicle":  TargetVehicle,
+}
+
+// GroundTargetModel is a static target placed on the ground by the scenario
+type GroundTargetModel struct {
+	Name      string             `json:"name"`
+	Kind      string             `json:"kind"`      // "building", "aa" or "vehicle"
+	Location  mathutils.Vector3D `json:"location"`  // Y is the height above the ground
+	Life      uint16             `json:"life"`      // Damage the target can take before it's destroyed
+	HitRadius float64            `json:"hitRadius"` // Radius of the sphere that contains the target
+	Team      uint8              `json:"team"`      // The turret doesn't shoot this team. 0 means it shoots everybody
+	Turret    *TurretModel       `json:"turret"`    // Makes the target an anti-aircraft gun
+}
+
+// GroundTarget is the state of a ground target
+type GroundTarget struct {
+	id       uint8
+	kind     uint8
+	model    GroundTargetModel
+	location mathutils.Vector3D
+	life     uint16
+	changed  bool // Damaged since the last report
+}
+
+// NewGroundTarget places the target on the terrain
+func NewGroundTarget(id uint8, model GroundTargetModel, terrain *Terrain) *GroundTarget {
+
+	location := model.Location
+	if terrain != nil {
+		location.Y += terrain.HeightAt(location)
+	}
+
+	kind, ok := targetKinds[model.Kind]
+	if !ok {
+		log.Printf("Unknown kind %q for the target %s, using building\n", model.Kind, model.Name)
+		kind = TargetBuilding
+	}
+
+	return &GroundTarget{
+		id:       id,
+		kind:     kind,
+		model:    model,
+		location: location,
+		life:     model.Life,
+	}
+}
+
+func (g *GroundTarget) position() mathutils.Vector3D {
+	return g.location
+}
+
+func (g *GroundTarget) hitRadius() float64 {
+	return g.model.HitRadius
+}
+
+func (g *GroundTarget) isDead() bool {
+	return g.life == 0
+}
+
+// isHitBy checks if the bullet went through the target during its last move.
+// Any player can hit it, but not the NPCs
+func (g *GroundTarget) isHitBy(b *Bullet) (t float64, hit bool) {
+	if g.isDead() || b.source == NPCSource {
+		return 0, false
+	}
+	return mathutils.SegmentSphereIntersection(b.previous, b.location, g.location, g.hitRadius())
+}
+
+// takeDamage removes "damage" from the life of the target
+func (g *GroundTarget) takeDamage(damage uint8, cause uint8) {
+	if g.isDead() {
+		return
+	}
+	g.life -= uint16(math.Min(float64(damage), float64(g.life)))
+	g.changed = true
+}
+
+// damagePercentage returns the damage taken by the target in percents
+func (g *GroundTarget) damagePercentage() uint8 {
+	if g.model.Life == 0 {
+		return 100
+	}
+	return uint8(100 * uint(g.model.Life-g.life) / uint(g.model.Life))
+}
+
+// placeTargets creates the ground targets of the scenario, and the NPCs that drive some of them
+func (w *World) placeTargets() {
+
+	w.targets = []*GroundTarget{}
+
+	w.npcs = []NPC{}
+
+	for i, model := range w.scenario.Targets {
+
+		if i == MaxTargets {
+			log.Printf("Too many targets, only the first %d are placed\n", MaxTargets)
+			break
+		}
+		target := NewGroundTarget(uint8(i), model, w.terrain)
+		w.targets = append(w.targets, target)
+
+		if model.Turret != nil {
+			w.npcs = append(w.npcs, NewTurret(target, *model.Turret))
+		}
+	}
+}
+
+// reportTargets tells the players about the targets that were damaged during the tick
+func (w *World) reportTargets() {
+
+	changed := false
+	for _, target := range w.targets {
+		if target.changed {
+			changed = true
+			target.changed = false
+		}
+	}
+
+	if changed {
+		w.emit(targetsMessage(w.targets))
+	}
+}
+
+// targetsMessage describes all the ground targets:
+// 0xD|count|(id|kind|damage percentage|location (float32 * 3))...
+func targetsMessage(targets []*GroundTarget) []byte {
+
+	const targetSize = 1 + 1 + 1 + 3*4
+
+	message := make([]byte, 1+1+len(targets)*targetSize)
+	message[0] = 0xD // Ground targets
+	message[1] = uint8(len(targets))
+
+	offset := 2
+	for _, target := range targets {
+		message[offset] = target.id
+		message[offset+1] = target.kind
+		message[offset+2] = target.damagePercentage()
+		putVector3D(message[offset+3:], target.location)
+		offset += targetSize
+	}
+	return message
+}
@@ -0,0 +1,71 @@
+package world
+
+// Friendly fire settings
+const (
+	// FriendlyFireFull : the teammates take the full damage
+	FriendlyFireFull = "full"
+	// FriendlyFireReduced : the teammates take a part of the damage (Rules.FriendlyFireRatio)
+	FriendlyFireReduced = "reduced"
+	// FriendlyFireOff : the teammates can't hurt each other
+	FriendlyFireOff = "off"
+	// defaultFriendlyFireRatio is used when the rules don't set one
+	defaultFriendlyFireRatio = 0.5
+)
+
+// teamOf returns the team of the plane "uid". 0 (no team) if it's not in the world
+func (w *World) teamOf(uid uint8) uint8 {
+	if plane, exists := w.planes[uid]; exists {
+		return plane.team
+	}
+	return 0
+}
+
+// targetTeam returns the team of something that can be damaged
+func targetTeam(target damageable) uint8 {
+	switch t := target.(type) {
+	case *Plane:
+		return t.team
+	case *GroundTarget:
+		return t.model.Team
+	}
+	return 0
+}
+
+// damageFrom returns the damage that the plane "source" really deals to the target, according to the
+// friendly fire rule. Planes without a team (0) are everybody's enemies.
+func (w *World) damageFrom(source uint8, target damageable, damage uint8) uint8 {
+
+	team := w.teamOf(source)
+	if team == 0 || team != targetTeam(target) {
+		return damage
+	}
+
+	switch w.rules.FriendlyFire {
+	case FriendlyFireOff:
+		return 0
+	case FriendlyFireReduced:
+		return uint8(float64(damage) * w.rules.FriendlyFireRatio)
+	}
+	return damage
+}
+
+// hurt deals the damage of the plane "source" to the target, if the friendly fire allows it.
+// The hit is recorded for the scores
+func (w *World) hurt(source uint8, target damageable, damage uint8) {
+
+	if damage = w.damageFrom(source, target, damage); damage == 0 || target.isDead() {
+		return
+	}
+
+	// Only the life that was left counts
+	if plane, isPlane := target.(*Plane); isPlane && damage > plane.life {
+		damage = plane.life
+	}
+	target.takeDamage(damage, PlaneDestroyed)
+
+	if plane, isPlane := target.(*Plane); isPlane {
+		w.recordDamage(source, plane, damage)
+	} else if target.isDead() {
+		w.creditTarget(source, target)
+	}
+}
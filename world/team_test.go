@@ -0,0 +1,88 @@
+package world
+
+import (
+	"testing"
+
+	"github.com/eaglesight/eaglesight-server/mathutils"
+)
+
+func TestFriendlyFire(t *testing.T) {
+
+	tests := []struct {
+		friendlyFire string
+		damage       uint8
+	}{
+		{FriendlyFireFull, 40},
+		{"", 40},
+		{FriendlyFireReduced, 20},
+		{FriendlyFireOff, 0},
+	}
+
+	for _, test := range tests {
+		w := newTestWorld(Scenario{}, Rules{FriendlyFire: test.friendlyFire}, 1, 1, 2)
+
+		if damage := w.damageFrom(1, w.planes[2], 40); damage != test.damage {
+			t.Errorf("%q: a teammate should take %d damage, not %d", test.friendlyFire, test.damage, damage)
+		}
+
+		if damage := w.damageFrom(1, w.planes[3], 40); damage != 40 {
+			t.Errorf("%q: an enemy should take the full damage, not %d", test.friendlyFire, damage)
+		}
+	}
+}
+
+func TestFriendlyFireDefaults(t *testing.T) {
+
+	if rules := (Rules{FriendlyFire: "none"}).withDefaults(); rules.FriendlyFire != FriendlyFireFull {
+		t.Errorf("An unknown friendly fire should be full, not %q", rules.FriendlyFire)
+	}
+
+	if rules := (Rules{FriendlyFire: FriendlyFireOff}).withDefaults(); rules.FriendlyFire != FriendlyFireOff || rules.FriendlyFireRatio != defaultFriendlyFireRatio {
+		t.Errorf("Wrong defaults: %+v", rules)
+	}
+}
+
+func TestNoTeamNoFriend(t *testing.T) {
+	w := getTestWorld()
+	w.rules.FriendlyFire = FriendlyFireOff
+
+	w.addPlane(1, 0, PlaneModel{Life: 100}, w.gun)
+	w.addPlane(2, 0, PlaneModel{Life: 100}, w.gun)
+
+	if damage := w.damageFrom(1, w.planes[2], 40); damage != 40 {
+		t.Errorf("Planes without a team are enemies: %d damage", damage)
+	}
+}
+
+func TestShootTeammate(t *testing.T) {
+	w := newTestWorld(Scenario{}, Rules{FriendlyFire: FriendlyFireOff}, 1, 1, 2)
+	teammate := w.planes[2]
+
+	w.addBullet(&Bullet{
+		source:   1,
+		previous: teammate.location.Add(mathutils.Vector3D{X: 0, Y: 0, Z: -20}),
+		location: teammate.location,
+		damage:   10,
+	})
+
+	w.indexEntities()
+	w.detectHits(0.01)
+
+	if len(w.bullets) != 0 || teammate.life != 100 {
+		t.Errorf("The bullet should hit the teammate without damage: %d", teammate.life)
+	}
+}
+
+func TestSeekerIgnoresTeammates(t *testing.T) {
+	w := newTestWorld(Scenario{}, Rules{FriendlyFire: FriendlyFireFull}, 1, 1, 2)
+	shooter := w.planes[1]
+
+	// The teammate is right in front of the seeker
+	w.planes[2].location = shooter.location.Add(mathutils.Vector3D{X: 0, Y: 0, Z: 1000})
+	w.planes[3].location = shooter.location.Add(mathutils.Vector3D{X: 0, Y: 0, Z: -1000})
+
+	model := dummyMissileModel()
+	if target := w.seek(shooter, &model, w.sortedPlanes()); target != nil {
+		t.Errorf("The seeker should not lock a teammate: %d", target.UID)
+	}
+}
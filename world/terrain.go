@@ -62,42 +62,144 @@ func LoadTerrain(filePath string) (*Terrain, error) {
 func (t *Terrain) OverredTriangle(pos mathutils.Vector3D) (s [3]mathutils.Vector3D) {
 	// 0 1
 	// 2 3
-	col := uint(math.Ceil(pos.X / t.distance)) // X
-	row := uint(math.Ceil(pos.Z / t.distance)) // Z
+	// Stay signed: a negative coordinate must not wrap around
+	col := int(math.Floor(pos.X / t.distance)) // X
+	row := int(math.Floor(pos.Z / t.distance)) // Z
+
+	triangles, ok := t.cellTriangles(col, row)
 
 	// We check if we are out of bound
-	if col < 0 || col >= t.width-1 || row < 0 || row >= t.depth-1 {
+	if !ok {
 		s[0].X = math.NaN()
 		return s // s[0] == NaN if out of bound
 	}
-	// UP LEFT
-	index0 := row*t.width + col
-	s[0].X = float64(index0%t.width) * t.distance
-	s[0].Y = float64(t.points[index0])
-	s[0].Z = math.Ceil(float64(index0/t.width)) * t.distance
 
 	if math.Mod(pos.X, t.distance) > math.Mod(pos.Z, t.distance) {
-		// DOWN RIGHT
-		index1 := index0 + 1 + t.width
-		s[1].X = s[0].X + t.distance
-		s[1].Y = float64(t.points[index1])
-		s[1].Z = s[0].Z + t.distance
-		// UP RIGHT
-		index2 := index0 + 1
-		s[2].X = s[1].X
-		s[2].Y = float64(t.points[index2])
-		s[2].Z = s[0].Z
-	} else {
-		// DOWN LEFT
-		index1 := index0 + t.width
-		s[1].X = s[0].X
-		s[1].Y = float64(t.points[index1])
-		s[1].Z = s[0].Z + t.distance
-		// DOWN RIGHT
-		index2 := index0 + 1 + t.width
-		s[2].X = s[0].X + t.distance
-		s[2].Y = float64(t.points[index2])
-		s[2].Z = s[1].Z
+		// UP LEFT, DOWN RIGHT, UP RIGHT
+		return triangles[0]
+	}
+	// UP LEFT, DOWN LEFT, DOWN RIGHT
+	return triangles[1]
+}
+
+// Clamp returns the closest position above the heightmap. Beyond the map, the ground continues
+// at the height of its edge
+func (t *Terrain) Clamp(pos mathutils.Vector3D) mathutils.Vector3D {
+	// Stay a bit inside so we don't land on the last row or column
+	const inside = 1e-6
+	pos.X = math.Max(0, math.Min(pos.X, float64(t.width-1)*t.distance-inside))
+	pos.Z = math.Max(0, math.Min(pos.Z, float64(t.depth-1)*t.distance-inside))
+
+	return pos
+}
+
+// HeightAt returns the height of the ground under pos. Beyond the map, it's the height of the closest edge
+func (t *Terrain) HeightAt(pos mathutils.Vector3D) float64 {
+	ground := t.Clamp(pos)
+	triangle := t.OverredTriangle(ground)
+	return mathutils.HeightOnTriangle(ground, &triangle)
+}
+
+// vertex returns the point of the heightmap at (col, row)
+func (t *Terrain) vertex(col int, row int) mathutils.Vector3D {
+	return mathutils.Vector3D{
+		X: float64(col) * t.distance,
+		Y: float64(t.points[row*int(t.width)+col]),
+		Z: float64(row) * t.distance,
+	}
+}
+
+// cellTriangles returns the 2 triangles of the cell (col, row), the same way OverredTriangle cuts it.
+// ok is false if the cell is out of bound
+func (t *Terrain) cellTriangles(col int, row int) (triangles [2][3]mathutils.Vector3D, ok bool) {
+
+	if col < 0 || col >= int(t.width)-1 || row < 0 || row >= int(t.depth)-1 {
+		return triangles, false
+	}
+	upLeft := t.vertex(col, row)
+	upRight := t.vertex(col+1, row)
+	downLeft := t.vertex(col, row+1)
+	downRight := t.vertex(col+1, row+1)
+
+	triangles[0] = [3]mathutils.Vector3D{upLeft, downRight, upRight}
+	triangles[1] = [3]mathutils.Vector3D{upLeft, downLeft, downRight}
+	return triangles, true
+}
+
+// SegmentIntersection walks through the cells crossed by the segment [a, b] (seen from above)
+// and returns where it goes through the ground first, with the normal of the surface.
+// at is where it happens on the segment: 0 is a, 1 is b.
+func (t *Terrain) SegmentIntersection(a mathutils.Vector3D, b mathutils.Vector3D) (at float64, normal mathutils.Vector3D, hit bool) {
+
+	direction := b.Sub(a)
+
+	col := int(math.Floor(a.X / t.distance))
+	row := int(math.Floor(a.Z / t.distance))
+	lastCol := int(math.Floor(b.X / t.distance))
+	lastRow := int(math.Floor(b.Z / t.distance))
+
+	stepCol, nextCol, deltaCol := t.traversal(a.X, direction.X, col)
+	stepRow, nextRow, deltaRow := t.traversal(a.Z, direction.Z, row)
+
+	// Every step goes to a neighbour cell, so we know how many cells there are
+	cells := abs(lastCol-col) + abs(lastRow-row) + 1
+
+	for i := 0; i < cells; i++ {
+
+		if triangles, ok := t.cellTriangles(col, row); ok {
+			for j := range triangles {
+				if crossing, crosses := mathutils.SegmentTriangleIntersection(a, b, &triangles[j]); crosses && (!hit || crossing < at) {
+					at = crossing
+					normal = triangleNormal(&triangles[j])
+					hit = true
+				}
+			}
+			// The cells are sorted along the segment: nothing can be hit before
+			if hit {
+				return at, normal, true
+			}
+		}
+
+		// Go to the next cell, through the closest border
+		if nextCol < nextRow {
+			col += stepCol
+			nextCol += deltaCol
+		} else {
+			row += stepRow
+			nextRow += deltaRow
+		}
+	}
+	return 0, normal, false
+}
+
+// traversal returns how to walk through the cells along one axis: the direction of the step,
+// where the next border is crossed and the distance between 2 borders (both as a fraction of the segment)
+func (t *Terrain) traversal(origin float64, direction float64, cell int) (step int, next float64, delta float64) {
+	switch {
+	case direction > 0:
+		return 1, (float64(cell+1)*t.distance - origin) / direction, t.distance / direction
+	case direction < 0:
+		return -1, (float64(cell)*t.distance - origin) / direction, -t.distance / direction
+	default:
+		return 0, math.Inf(1), math.Inf(1)
+	}
+}
+
+// triangleNormal returns the unit normal of the triangle, pointing to the sky
+func triangleNormal(triangle *[3]mathutils.Vector3D) mathutils.Vector3D {
+	v := triangle[1].Sub(triangle[0])
+	w := triangle[2].Sub(triangle[0])
+	n := mathutils.CrossProduct(&v, &w)
+
+	if n.Y < 0 {
+		n = n.MulScalar(-1)
+	}
+	return n.DivScalar(n.Length())
+}
+
+func abs(x int) int {
+	if x < 0 {
+		return -x
 	}
-	return s
+	return x
 }
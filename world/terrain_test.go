@@ -0,0 +1,110 @@
+package world
+
+import (
+	"testing"
+
+	"github.com/eaglesight/eaglesight-server/mathutils"
+)
+
+// ridgeTerrain returns a flat terrain at 0 with a ridge of "height" along the column "ridge"
+func ridgeTerrain(ridge uint, height uint16) *Terrain {
+	t := &Terrain{
+		width:    16,
+		depth:    16,
+		distance: 10,
+	}
+	t.points = make([]uint16, t.width*t.depth)
+
+	for row := uint(0); row < t.depth; row++ {
+		t.points[row*t.width+ridge] = height
+	}
+	return t
+}
+
+func TestOverredTriangle(t *testing.T) {
+	terrain := ridgeTerrain(8, 100)
+
+	// Halfway up the ridge, in the cell of the column 7. The cell of the column 8 goes down
+	// on the other side: its triangles would put the ground at 150 here
+	pos := mathutils.Vector3D{X: 75, Y: 0, Z: 42}
+	triangle := terrain.OverredTriangle(pos)
+
+	if triangle[0].X != 70 || triangle[0].Z != 40 {
+		t.Errorf("The triangle should be in the cell under the position: %+v", triangle)
+	}
+
+	if height := terrain.HeightAt(pos); !isClose(height, 50, 1e-9) {
+		t.Errorf("The ground should be at 50, not %f", height)
+	}
+}
+
+func TestSegmentThroughRidge(t *testing.T) {
+	terrain := ridgeTerrain(8, 100)
+
+	// Both ends are high above the flat ground, but the segment crosses the ridge in the middle
+	a := mathutils.Vector3D{X: 15, Y: 50, Z: 42}
+	b := mathutils.Vector3D{X: 135, Y: 50, Z: 47}
+
+	at, normal, hit := terrain.SegmentIntersection(a, b)
+
+	if !hit {
+		t.Fatal("The segment should hit the ridge")
+	}
+
+	location := mathutils.Lerp(a, b, at)
+	// The ridge's side goes from 0 at X=70 to 100 at X=80
+	if !isClose(location.X, 75, 1e-6) {
+		t.Errorf("The impact should be at X=75, not %+v", location)
+	}
+
+	// The side of the ridge faces -X
+	if normal.X >= 0 || normal.Y <= 0 || !isClose(normal.Length(), 1, 1e-9) {
+		t.Errorf("Wrong normal: %+v", normal)
+	}
+}
+
+func TestSegmentBackwards(t *testing.T) {
+	terrain := ridgeTerrain(8, 100)
+
+	// Same thing, going the other way
+	a := mathutils.Vector3D{X: 135, Y: 50, Z: 47}
+	b := mathutils.Vector3D{X: 15, Y: 50, Z: 42}
+
+	at, normal, hit := terrain.SegmentIntersection(a, b)
+
+	if !hit || !isClose(mathutils.Lerp(a, b, at).X, 85, 1e-6) || normal.X <= 0 {
+		t.Errorf("The segment should hit the other side of the ridge: %f, %+v", at, normal)
+	}
+}
+
+func TestSegmentAboveTerrain(t *testing.T) {
+	terrain := ridgeTerrain(8, 100)
+
+	a := mathutils.Vector3D{X: 15, Y: 150, Z: 42}
+	b := mathutils.Vector3D{X: 135, Y: 150, Z: 47}
+
+	if _, _, hit := terrain.SegmentIntersection(a, b); hit {
+		t.Error("The segment is above the ridge")
+	}
+
+	// Out of the map
+	a = mathutils.Vector3D{X: -50, Y: -10, Z: -50}
+	b = mathutils.Vector3D{X: -20, Y: -10, Z: -80}
+
+	if _, _, hit := terrain.SegmentIntersection(a, b); hit {
+		t.Error("There is no ground out of the map")
+	}
+}
+
+func TestSegmentStraightDown(t *testing.T) {
+	terrain := ridgeTerrain(8, 100)
+
+	a := mathutils.Vector3D{X: 33, Y: 10, Z: 33}
+	b := mathutils.Vector3D{X: 33, Y: -10, Z: 33}
+
+	at, normal, hit := terrain.SegmentIntersection(a, b)
+
+	if !hit || !isClose(at, 0.5, 1e-9) || !isClose(normal.Y, 1, 1e-9) {
+		t.Errorf("The segment should hit the flat ground in its middle: %f, %+v", at, normal)
+	}
+}
@@ -0,0 +1,121 @@
+package world
+
+import (
+	"encoding/binary"
+	"math"
+	"math/rand"
+	"sort"
+
+	"github.com/eaglesight/eaglesight-server/mathutils"
+)
+
+// WindLayer is the wind blowing at a given altitude
+type WindLayer struct {
+	Altitude float64            `json:"altitude"`
+	Speed    mathutils.Vector3D `json:"speed"` // unit / seconds
+}
+
+// Wind describes the movement of the air mass in a map.
+// The wind is the base wind + the wind of the layers (interpolated between them) + the gusts
+type Wind struct {
+	Base       mathutils.Vector3D `json:"base"`       // Blows everywhere
+	Layers     []WindLayer        `json:"layers"`     // Sorted by altitude when the world is created
+	Gusts      float64            `json:"gusts"`      // Average strength of the gusts. 0 means no gusts
+	GustPeriod float64            `json:"gustPeriod"` // Seconds it takes for a gust to fade away
+	Seed       int64              `json:"seed"`       // The same seed always gives the same gusts
+	gust       mathutils.Vector3D
+	random     *rand.Rand
+}
+
+// defaultGustPeriod is used when the gust period is not set
+const defaultGustPeriod = 5
+
+// withDefaults returns the wind ready to be updated, with its layers sorted by altitude
+func (wind Wind) withDefaults() Wind {
+
+	if wind.GustPeriod <= 0 {
+		wind.GustPeriod = defaultGustPeriod
+	}
+	// A copy, the scenario's layers stay as they are
+	wind.Layers = append([]WindLayer(nil), wind.Layers...)
+	sort.SliceStable(wind.Layers, func(i, j int) bool { return wind.Layers[i].Altitude < wind.Layers[j].Altitude })
+
+	wind.gust = mathutils.Vector3D{}
+	wind.random = rand.New(rand.NewSource(wind.Seed))
+	return wind
+}
+
+// update makes the gusts evolve. They randomly drift around 0 (Ornstein-Uhlenbeck process)
+func (wind *Wind) update(deltaT float64) {
+
+	if wind.Gusts == 0 {
+		return
+	}
+
+	decay := deltaT / wind.GustPeriod
+	noise := wind.Gusts * math.Sqrt(2*decay)
+
+	wind.gust.X += -wind.gust.X*decay + noise*wind.random.NormFloat64()
+	wind.gust.Y += -wind.gust.Y*decay + noise*wind.random.NormFloat64()*0.2 // Vertical gusts are weaker
+	wind.gust.Z += -wind.gust.Z*decay + noise*wind.random.NormFloat64()
+}
+
+// At returns the speed of the air at "location"
+func (wind *Wind) At(location mathutils.Vector3D) mathutils.Vector3D {
+
+	speed := wind.Base.Add(wind.gust)
+	return speed.Add(wind.layerAt(location.Y))
+}
+
+// layerAt interpolates the wind of the layers at the altitude y
+func (wind *Wind) layerAt(y float64) mathutils.Vector3D {
+
+	if len(wind.Layers) == 0 {
+		return mathutils.Vector3D{}
+	}
+
+	if y <= wind.Layers[0].Altitude {
+		return wind.Layers[0].Speed
+	}
+
+	for i := 1; i < len(wind.Layers); i++ {
+
+		below := wind.Layers[i-1]
+		above := wind.Layers[i]
+
+		if y < above.Altitude {
+			ratio := (y - below.Altitude) / (above.Altitude - below.Altitude)
+			difference := above.Speed.Sub(below.Speed)
+			return below.Speed.Add(difference.MulScalar(ratio))
+		}
+	}
+	return wind.Layers[len(wind.Layers)-1].Speed
+}
+
+// windMessage reports the wind to the players:
+// 0x6|gusting base wind (float32 * 3)|layers count|(altitude (float32)|speed (float32 * 3))...
+func windMessage(wind *Wind) []byte {
+
+	message := make([]byte, 1+3*4+1+len(wind.Layers)*4*4)
+	message[0] = 0x6 // Wind
+
+	base := wind.Base.Add(wind.gust)
+	putVector3D(message[1:], base)
+
+	message[13] = uint8(len(wind.Layers))
+	offset := 14
+
+	for _, layer := range wind.Layers {
+		binary.BigEndian.PutUint32(message[offset:], math.Float32bits(float32(layer.Altitude)))
+		putVector3D(message[offset+4:], layer.Speed)
+		offset += 4 * 4
+	}
+	return message
+}
+
+// putVector3D writes v in b as 3 float32
+func putVector3D(b []byte, v mathutils.Vector3D) {
+	binary.BigEndian.PutUint32(b[0:], math.Float32bits(float32(v.X)))
+	binary.BigEndian.PutUint32(b[4:], math.Float32bits(float32(v.Y)))
+	binary.BigEndian.PutUint32(b[8:], math.Float32bits(float32(v.Z)))
+}
@@ -0,0 +1,88 @@
+package world
+
+import (
+	"encoding/binary"
+	"math"
+	"math/rand"
+	"testing"
+
+	"github.com/eaglesight/eaglesight-server/mathutils"
+)
+
+func dummyWind() Wind {
+	return Wind{
+		Base: mathutils.Vector3D{X: 2, Y: 0, Z: 0},
+		Layers: []WindLayer{
+			{Altitude: 1000, Speed: mathutils.Vector3D{X: 0, Y: 0, Z: 10}},
+			{Altitude: 3000, Speed: mathutils.Vector3D{X: 0, Y: 0, Z: 30}},
+		},
+	}.withDefaults()
+}
+
+func TestWindLayers(t *testing.T) {
+	wind := dummyWind()
+
+	values := map[float64]float64{0: 10, 1000: 10, 2000: 20, 3000: 30, 9000: 30}
+
+	for y, z := range values {
+		speed := wind.At(mathutils.Vector3D{X: 0, Y: y, Z: 0})
+
+		if speed.X != 2 || speed.Z != z {
+			t.Errorf("The wind at %.0f should be (2, 0, %.0f), not %+v", y, z, speed)
+		}
+	}
+}
+
+func TestUnsortedWindLayers(t *testing.T) {
+	wind := Wind{
+		Layers: []WindLayer{
+			{Altitude: 3000, Speed: mathutils.Vector3D{X: 0, Y: 0, Z: 30}},
+			{Altitude: 1000, Speed: mathutils.Vector3D{X: 0, Y: 0, Z: 10}},
+		},
+	}.withDefaults()
+
+	if speed := wind.At(mathutils.Vector3D{X: 0, Y: 2000, Z: 0}); speed.Z != 20 {
+		t.Errorf("The layers should be sorted by altitude: %+v", speed)
+	}
+}
+
+func TestGusts(t *testing.T) {
+	calm := dummyWind()
+	gusty := dummyWind()
+	gusty.Gusts = 5
+	other := gusty
+
+	calm.update(1)
+	if calm.gust != (mathutils.Vector3D{}) {
+		t.Error("There should be no gust")
+	}
+
+	gusty.random = rand.New(rand.NewSource(gusty.Seed))
+	other.random = rand.New(rand.NewSource(other.Seed))
+
+	for i := 0; i < 100; i++ {
+		gusty.update(0.01)
+		other.update(0.01)
+	}
+
+	if gusty.gust == (mathutils.Vector3D{}) || gusty.gust != other.gust {
+		t.Errorf("The gusts should be the same with the same seed: %+v / %+v", gusty.gust, other.gust)
+	}
+}
+
+func TestWindMessage(t *testing.T) {
+	wind := dummyWind()
+	message := windMessage(&wind)
+
+	if message[0] != 0x6 || message[13] != 2 || len(message) != 14+2*16 {
+		t.Errorf("Wrong message: %v", message)
+	}
+
+	if math.Float32frombits(binary.BigEndian.Uint32(message[1:])) != 2 {
+		t.Errorf("Wrong base wind: %v", message[1:13])
+	}
+
+	if math.Float32frombits(binary.BigEndian.Uint32(message[30:])) != 3000 {
+		t.Errorf("Wrong layer: %v", message[30:46])
+	}
+}
@@ -1,10 +1,27 @@
 package world
 
 import (
+	"encoding/binary"
 	"log"
+	"math"
+	"math/rand"
+	"sort"
 	"time"
 )
 
+const (
+	// gunCapacity is the number of bullets that can be fired in the world between two collections
+	gunCapacity = 256
+	// SnapshotVersion is the version of the snapshots' format
+	SnapshotVersion = 4
+	// defaultTimeStep is the duration of a tick (in seconds) until Run sets it
+	defaultTimeStep = 1.0 / 100
+	// maxCatchUpSteps is the maximum of ticks run at once when the simulation is late
+	maxCatchUpSteps = 5
+	// windReportInterval is the time in seconds between two wind messages
+	windReportInterval = 1
+)
+
 // PlayerInput contains input data and the uid to which it is attributed
 type PlayerInput struct {
 	UID  uint8
@@ -13,47 +30,117 @@ type PlayerInput struct {
 
 // World World is which everything happens
 type World struct {
-	Input     chan PlayerInput
-	Snapshots chan []byte
-	join      chan struct {
-		UID   uint8
-		Model PlaneModel
-	}
-	leave   chan uint8
-	End     chan bool // End the world
-	gun     chan *Bullet
-	terrain *Terrain
-	planes  map[uint8]*Plane
-	bullets []*Bullet
+	Input      chan PlayerInput
+	Snapshots  chan []byte
+	join       chan arrival
+	leave      chan uint8
+	End        chan bool         // End the world
+	Over       chan MatchResults // Results of every match that ends
+	Events     chan Event
+	gun        chan *Bullet
+	terrain    *Terrain
+	scenario   Scenario
+	atmosphere *Atmosphere
+	bounds     *Bounds
+	rules      Rules
+	planes     map[uint8]*Plane
+	bullets    []*Bullet
+	missiles   []*Missile
+	decoys     []*Decoy
+	bombs      []*Bomb
+	targets    []*GroundTarget
+	npcs       []NPC
+	random     *rand.Rand
+	// Index of the entities, rebuilt every tick
+	grid *spatialGrid
+	// Planes touching each other during the last tick
+	contacts map[planesPair]bool
+	// Seconds left before the planes that are no more respawn
+	respawns map[uint8]float64
+	// Scores of the match, read by the game loop
+	scoreboard *Scoreboard
+	// Match
+	mode          GameMode
+	phase         uint8
+	phaseTimeLeft float64 // Seconds before the end of the phase
+	winner        uint8
+	// Messages waiting to be sent with the next snapshot
+	events       []Event
+	windReportIn float64 // Seconds before the wind is sent again
+	// Fixed timestep
+	tick        uint64        // Number of ticks since the world started
+	timeStep    float64       // Duration of a tick in seconds
+	accumulator float64       // Time (in seconds) not simulated yet
+	inputs      []PlayerInput // Inputs waiting for the next tick
+	// Projectiles' changes since the last snapshot
+	nextProjectileID uint16
+	spawned          []*Bullet
+	despawned        []uint16
 }
 
 // NewWorld Creates a new world
-func NewWorld(terrain *Terrain) *World {
+func NewWorld(terrain *Terrain, scenario Scenario, rules Rules) *World {
+
+	atmosphere := scenario.Atmosphere.withDefaults()
+	rules = rules.withDefaults()
+	bounds := scenario.Bounds.withDefaults(terrain)
+	scenario.Rings = validRings(scenario.Rings)
 
 	world := &World{
-		terrain:   terrain,
-		planes:    make(map[uint8]*Plane),
-		Snapshots: make(chan []byte, 1),
-		Input:     make(chan PlayerInput, 1),
-		join: make(chan struct {
-			UID   uint8
-			Model PlaneModel
-		}, 1),
-		leave:   make(chan uint8, 1),
-		End:     make(chan bool),
-		gun:     make(chan *Bullet, 1),
-		bullets: []*Bullet{},
+		terrain:    terrain,
+		scenario:   scenario,
+		atmosphere: &atmosphere,
+		bounds:     &bounds,
+		rules:      rules,
+		planes:     make(map[uint8]*Plane),
+		respawns:   make(map[uint8]float64),
+		contacts:   make(map[planesPair]bool),
+		grid:       newSpatialGrid(terrain),
+		timeStep:   defaultTimeStep,
+		inputs:     []PlayerInput{},
+		events:     []Event{},
+		Events:     make(chan Event, 16),
+		Snapshots:  make(chan []byte, 1),
+		Input:      make(chan PlayerInput, 1),
+		join:       make(chan arrival, 1),
+		leave:      make(chan uint8, 1),
+		End:        make(chan bool),
+		Over:       make(chan MatchResults, 1),
+		gun:        make(chan *Bullet, gunCapacity),
+		bullets:    []*Bullet{},
+		missiles:   []*Missile{},
+		decoys:     []*Decoy{},
+		bombs:      []*Bomb{},
+		random:     rand.New(rand.NewSource(rules.Seed)),
+		mode:       newGameMode(rules.Mode),
+		scoreboard: NewScoreboard(),
+		winner:     NoWinner,
+		spawned:    []*Bullet{},
+		despawned:  []uint16{},
 	}
+	world.placeTargets()
+	world.updateMatch(0)
+
 	return world
 }
 
 // Join ...
-func (w *World) Join(uid uint8, model PlaneModel) {
+func (w *World) Join(uid uint8, team uint8, model PlaneModel) {
 
-	w.join <- struct {
-		UID   uint8
-		Model PlaneModel
-	}{UID: uid, Model: model}
+	w.join <- arrival{UID: uid, Team: team, Model: model}
+}
+
+// arrival is a plane that joins the world
+type arrival struct {
+	UID   uint8
+	Team  uint8
+	Model PlaneModel
+}
+
+// arrive adds the plane that joined
+func (w *World) arrive(plane arrival) {
+	log.Println("Plane joining")
+	w.addPlane(plane.UID, plane.Team, plane.Model, w.gun)
 }
 
 // Leave ...
@@ -62,16 +149,73 @@ func (w *World) Leave(uid uint8) {
 	w.leave <- uid
 }
 
-// addPlane add a plane to the world
-func (w *World) addPlane(uid uint8, model PlaneModel, gun chan<- *Bullet) {
+// addPlane add a plane of the team to the world
+func (w *World) addPlane(uid uint8, team uint8, model PlaneModel, gun chan<- *Bullet) {
 	// Check if the plane already exists in the world
-	w.planes[uid] = NewPlane(uid, model, gun)
+	plane := NewPlane(uid, model, gun)
+	plane.team = team
+	w.scoreboard.join(uid, team)
+
+	if w.playing() {
+		w.mode.Join(w, plane)
+	}
+
+	if w.mode.CanRespawn(w, plane) {
+		plane.respawn(w.pickSpawnPoint(plane.team))
+	} else {
+		// It waits for the next match, like a plane that is no more
+		plane.isNoMore = true
+		w.respawns[uid] = 0
+	}
+
+	w.planes[uid] = plane
+
+	// The newcomer needs to know about the wind and the targets
+	w.windReportIn = 0
+
+	if len(w.targets) > 0 {
+		w.emitTo(uid, targetsMessage(w.targets))
+	}
+	w.emitTo(uid, phaseMessage(w.phase, w.phaseTimeLeft, w.winner))
 }
 
 // addBullet add the bullet in the world
 func (w *World) addBullet(bullet *Bullet) {
 
+	bullet.id = w.nextProjectileID
+	w.nextProjectileID++
+
 	w.bullets = append(w.bullets, bullet)
+	w.spawned = append(w.spawned, bullet)
+}
+
+// despawnBullet records that the bullet is no longer in the world.
+// A bullet that was never announced in a snapshot is simply forgotten.
+func (w *World) despawnBullet(bullet *Bullet) {
+
+	if bullet.announced {
+		w.despawned = append(w.despawned, bullet.id)
+		return
+	}
+
+	for i, b := range w.spawned {
+		if b == bullet {
+			w.spawned = append(w.spawned[:i], w.spawned[i+1:]...)
+			return
+		}
+	}
+}
+
+// collectBullets adds all the bullets waiting in the gun to the world
+func (w *World) collectBullets() {
+	for {
+		select {
+		case bullet := <-w.gun:
+			w.addBullet(bullet)
+		default:
+			return
+		}
+	}
 }
 
 // applyInput apply a UserInput to a plane in the world
@@ -79,51 +223,356 @@ func (w *World) applyInput(input *PlayerInput) {
 
 	plane, exists := w.planes[input.UID]
 
-	if exists {
+	// The planes are frozen outside of the match
+	if exists && w.playing() {
 		plane.Write(input.Data) // So the plane can process the data by itself
+
+		if !w.mode.Armed() {
+			plane.disarm()
+		}
 	}
 }
 
+// queueInput keeps the input until the next tick
+func (w *World) queueInput(input PlayerInput) {
+	w.inputs = append(w.inputs, input)
+}
+
+// step runs exactly one tick of the simulation
+func (w *World) step() {
+
+	// The inputs are applied at the tick boundary, in the order they arrived
+	for i := range w.inputs {
+		w.applyInput(&w.inputs[i])
+	}
+	w.inputs = []PlayerInput{}
+
+	w.updateWorld(w.timeStep)
+	w.tick++
+}
+
+// advance runs as many ticks as there are in "elapsed" seconds plus what was left from the previous call.
+// If the simulation is too late, the time that can't be caught up is dropped.
+func (w *World) advance(elapsed float64) {
+
+	w.accumulator += elapsed
+
+	for steps := 0; w.accumulator >= w.timeStep; steps++ {
+
+		if steps == maxCatchUpSteps {
+			log.Printf("World overrun: %d ticks dropped\n", int(w.accumulator/w.timeStep))
+			w.accumulator = math.Mod(w.accumulator, w.timeStep)
+			return
+		}
+
+		w.step()
+		w.accumulator -= w.timeStep
+	}
+}
+
+// sortedPlanes returns the planes ordered by UID so they are always processed in the same order
+func (w *World) sortedPlanes() []*Plane {
+
+	planes := make([]*Plane, 0, len(w.planes))
+
+	for _, plane := range w.planes {
+		planes = append(planes, plane)
+	}
+
+	sort.Slice(planes, func(i, j int) bool {
+		return planes[i].UID < planes[j].UID
+	})
+	return planes
+}
+
 // updateWorld updates the states of all the entities in the world
 func (w *World) updateWorld(deltaT float64) {
 
 	bulletsStillAlive := []*Bullet{}
 
+	w.updateMatch(deltaT)
+	w.atmosphere.update(deltaT)
+	w.reportWind(deltaT)
+
 	// Update all the bullets
 	for _, bullet := range w.bullets {
 
-		if bullet.Update(deltaT) {
+		if bullet.Update(deltaT, w.atmosphere) {
 			// The bullet is still alive. It goes to the next round.
 			bulletsStillAlive = append(bulletsStillAlive, bullet)
+		} else {
+			w.despawnBullet(bullet)
 		}
 	}
 	w.bullets = bulletsStillAlive
 
+	w.updateDecoys(deltaT)
+	w.updateMissiles(deltaT)
+	w.updateBombs(deltaT)
+
 	// Update all the planes
-	for _, plane := range w.planes {
-		plane.Update(deltaT, w.terrain)
+	for _, plane := range w.sortedPlanes() {
+
+		if plane.isDead() {
+			w.waitForRespawn(plane.UID, deltaT)
+			continue
+		}
+		plane.Update(deltaT, w.terrain, w.atmosphere)
+		w.checkBounds(plane, deltaT)
+	}
+
+	w.updateNPCs(deltaT)
+
+	// Take the bullets fired by the planes
+	w.collectBullets()
+
+	w.updateSeekers(deltaT)
+	w.launchMissiles(deltaT)
+	w.deployCountermeasures(deltaT)
+	w.distractMissiles()
+	w.dropBombs(deltaT)
+
+	w.indexEntities()
+	w.detectHits(deltaT)
+	w.detectDetonations()
+	w.detectBlasts()
+	w.detectCollisions()
+	w.detectDeaths()
+	w.reportTargets()
+}
+
+// detectDeaths reports the planes that are no more and starts their respawn countdown
+func (w *World) detectDeaths() {
+
+	for _, plane := range w.sortedPlanes() {
+
+		if _, waiting := w.respawns[plane.UID]; plane.isDead() && !waiting {
+			w.respawns[plane.UID] = w.rules.RespawnDelay
+			w.emit(planeStateMessage(plane.UID, plane.cause))
+
+			if w.playing() {
+				w.credit(plane)
+			}
+		}
+	}
+}
+
+// waitForRespawn counts down until the plane "uid" can respawn
+func (w *World) waitForRespawn(uid uint8, deltaT float64) {
+
+	// The death has not been reported yet
+	if _, waiting := w.respawns[uid]; !waiting {
+		return
+	}
+
+	w.respawns[uid] -= deltaT
+
+	if w.respawns[uid] <= 0 && w.mode.CanRespawn(w, w.planes[uid]) {
+		delete(w.respawns, uid)
+		plane := w.planes[uid]
+		plane.respawn(w.pickSpawnPoint(plane.team))
+		w.emit(planeStateMessage(uid, PlaneRespawned))
+	}
+}
+
+// reportWind sends the wind to the players every windReportInterval
+func (w *World) reportWind(deltaT float64) {
+
+	w.windReportIn -= deltaT
+
+	if w.windReportIn <= 0 {
+		w.emit(windMessage(&w.atmosphere.Wind))
+		w.windReportIn = windReportInterval
+	}
+}
+
+// emit queues a message that will be sent to all the players with the next snapshot
+func (w *World) emit(message []byte) {
+	w.events = append(w.events, Event{Data: message})
+}
+
+// emitTo queues a message that will be sent to the player "uid" only
+func (w *World) emitTo(uid uint8, message []byte) {
+	w.events = append(w.events, Event{Data: message, Private: true, Recipient: uid})
+}
+
+// emitTelemetry queues the telemetry of every living plane for its pilot
+func (w *World) emitTelemetry() {
+
+	for _, plane := range w.sortedPlanes() {
+		if !plane.isDead() {
+			w.emitTo(plane.UID, plane.telemetryMessage())
+		}
+	}
+}
+
+// flushEvents sends all the queued messages
+func (w *World) flushEvents() {
+
+	for _, event := range w.events {
+		w.Events <- event
+	}
+	w.events = []Event{}
+}
+
+// indexEntities puts all the living planes in the spatial grid
+func (w *World) indexEntities() {
+
+	w.grid.clear()
+
+	for _, plane := range w.sortedPlanes() {
+		if !plane.isDead() {
+			w.grid.insert(plane)
+		}
+	}
+
+	for _, target := range w.targets {
+		if !target.isDead() {
+			w.grid.insert(target)
+		}
 	}
 }
 
+// detectHits checks the move of every bullet against the terrain and the planes around it.
+// A bullet that hits a plane damages it. In any case, a bullet that hits something disappears
+func (w *World) detectHits(deltaT float64) {
+
+	bulletsStillAlive := []*Bullet{}
+
+	for _, bullet := range w.bullets {
+
+		impact, hit := w.sweepBullet(bullet, deltaT)
+
+		if !hit {
+			bulletsStillAlive = append(bulletsStillAlive, bullet)
+			continue
+		}
+
+		if impact.target != nil {
+			w.hurt(bullet.source, impact.target, bullet.damage)
+		} else {
+			w.emit(impactMessage(bullet, impact.location, impact.normal))
+		}
+		w.despawnBullet(bullet)
+	}
+	w.bullets = bulletsStillAlive
+}
+
 func (w *World) removePlane(uid uint8) {
 
-	if _, exists := w.planes[uid]; exists {
+	if plane, exists := w.planes[uid]; exists {
 		delete(w.planes, uid)
+		delete(w.respawns, uid)
+
+		if w.playing() {
+			w.mode.Leave(w, uid)
+		}
+
+		// Nobody can follow it anymore
+		for _, other := range w.planes {
+			if other.seeker.target == plane {
+				other.seeker = Seeker{}
+			}
+		}
+		for _, missile := range w.missiles {
+			if missile.target == plane {
+				missile.target = nil
+			}
+		}
 	}
 }
 
-// generateSnapshots generate a snapshot of the whole world
+// generateSnapshots generate a snapshot of the whole world:
+// 0x3|version|tick (uint32)|planes count (uint16)|planes...|spawns count (uint16)|spawns...|despawns count (uint16)|despawned ids (uint16)...
+// |missiles, decoys and bombs count (uint16)|missiles...|decoys...|bombs...|NPCs count (uint16)|NPCs...
+// Bullets are only sent when they spawn. The clients extrapolate them until they are despawned.
+// The other projectiles are sent in every snapshot (missiles are guided), until they are despawned too.
 func (w *World) generateSnapshots() []byte {
 
-	const snapshotSizeOverhead = 1 // opcode's length
-	snapshot := make([]byte, snapshotSizeOverhead+len(w.planes)*PlaneSnapshotSize)
+	const snapshotSizeOverhead = 1 + 1 + 4 + 2 + 2 + 2 + 2 + 2 // opcode + version + tick + counts
+	// The planes that are no more are not in the snapshot
+	alivePlanes := 0
+	for _, plane := range w.planes {
+		if !plane.isDead() {
+			alivePlanes++
+		}
+	}
+
+	size := snapshotSizeOverhead +
+		alivePlanes*PlaneSnapshotSize +
+		len(w.spawned)*ProjectileSnapshotSize +
+		len(w.despawned)*2 +
+		(len(w.missiles)+len(w.decoys)+len(w.bombs))*ProjectileSnapshotSize +
+		len(w.npcs)*NPCSnapshotSize
+
+	snapshot := make([]byte, size)
 	snapshot[0] = 0x3
-	offset := snapshotSizeOverhead
+	snapshot[1] = SnapshotVersion
+	binary.BigEndian.PutUint32(snapshot[2:], uint32(w.tick))
+	offset := 6
 
-	for _, plane := range w.planes {
+	// Planes
+	binary.BigEndian.PutUint16(snapshot[offset:], uint16(alivePlanes))
+	offset += 2
+
+	for _, plane := range w.sortedPlanes() {
+		if plane.isDead() {
+			continue
+		}
 		plane.Read(snapshot[offset:])
 		offset += PlaneSnapshotSize
 	}
+
+	// Projectiles that spawned since the last snapshot
+	binary.BigEndian.PutUint16(snapshot[offset:], uint16(len(w.spawned)))
+	offset += 2
+
+	for _, bullet := range w.spawned {
+		bullet.Read(snapshot[offset:])
+		bullet.announced = true
+		offset += ProjectileSnapshotSize
+	}
+
+	// Projectiles that disappeared since the last snapshot
+	binary.BigEndian.PutUint16(snapshot[offset:], uint16(len(w.despawned)))
+	offset += 2
+
+	for _, id := range w.despawned {
+		binary.BigEndian.PutUint16(snapshot[offset:], id)
+		offset += 2
+	}
+
+	// Missiles, decoys and bombs
+	binary.BigEndian.PutUint16(snapshot[offset:], uint16(len(w.missiles)+len(w.decoys)+len(w.bombs)))
+	offset += 2
+
+	for _, missile := range w.missiles {
+		missile.Read(snapshot[offset:])
+		offset += ProjectileSnapshotSize
+	}
+
+	for _, decoy := range w.decoys {
+		decoy.Read(snapshot[offset:])
+		offset += ProjectileSnapshotSize
+	}
+
+	for _, bomb := range w.bombs {
+		bomb.Read(snapshot[offset:])
+		offset += ProjectileSnapshotSize
+	}
+
+	// NPCs
+	binary.BigEndian.PutUint16(snapshot[offset:], uint16(len(w.npcs)))
+	offset += 2
+
+	for _, npc := range w.npcs {
+		npc.Read(snapshot[offset:])
+		offset += NPCSnapshotSize
+	}
+
+	w.spawned = []*Bullet{}
+	w.despawned = []uint16{}
+
 	return snapshot
 }
 
@@ -134,6 +583,9 @@ func (w *World) Run(simulationInterval time.Duration, snapshotInterval time.Dura
 	snapshotTimer := time.Tick(snapshotInterval)
 	lastTick := time.Now()
 
+	// The simulation always moves forward by steps of simulationInterval
+	w.timeStep = simulationInterval.Seconds()
+
 	for {
 
 		select {
@@ -142,16 +594,15 @@ func (w *World) Run(simulationInterval time.Duration, snapshotInterval time.Dura
 			return
 		case <-snapshotTimer:
 			w.Snapshots <- w.generateSnapshots()
+			w.emitTelemetry()
+			w.flushEvents()
 		case now := <-simulationTimer:
-			w.updateWorld(now.Sub(lastTick).Seconds())
+			w.advance(now.Sub(lastTick).Seconds())
 			lastTick = now
 		case input := <-w.Input:
-			w.applyInput(&input)
-		case bullet := <-w.gun:
-			w.addBullet(bullet)
+			w.queueInput(input)
 		case plane := <-w.join:
-			log.Println("Plane joining")
-			w.addPlane(plane.UID, plane.Model, w.gun)
+			w.arrive(plane)
 		case uid := <-w.leave:
 			log.Println("Plane leaving")
 			w.removePlane(uid)
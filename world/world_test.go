@@ -1,6 +1,7 @@
 package world
 
 import (
+	"encoding/binary"
 	"log"
 	"testing"
 	"time"
@@ -8,14 +9,45 @@ import (
 	"github.com/eaglesight/eaglesight-server/mathutils"
 )
 
-func getTestWorld() *World {
-	terrain, err := LoadTerrain("../map.esmap")
+// testTerrain is the map of the tests, loaded once
+var testTerrain *Terrain
+
+func loadTestTerrain() *Terrain {
+	if testTerrain == nil {
+		terrain, err := LoadTerrain("../map.esmap")
+
+		if err != nil {
+			log.Fatalln(err)
+		}
+		testTerrain = terrain
+	}
+	return testTerrain
+}
+
+// newTestWorld returns a world on the test map with the planes 1 to len(teams), in these teams.
+// The match goes as far as the rules let it
+func newTestWorld(scenario Scenario, rules Rules, teams ...uint8) *World {
+	w := NewWorld(loadTestTerrain(), scenario, rules)
 
-	if err != nil {
-		log.Fatalln(err)
+	for i, team := range teams {
+		w.addPlane(uint8(i+1), team, PlaneModel{Life: 100}, w.gun)
 	}
+	w.updateMatch(0)
+	return w
+}
 
-	return NewWorld(terrain)
+func getTestWorld() *World {
+	return newTestWorld(Scenario{}, Rules{RespawnDelay: 1})
+}
+
+// eventsWithOpcode returns the queued messages starting with "opcode"
+func eventsWithOpcode(w *World, opcode byte) (events [][]byte) {
+	for _, event := range w.events {
+		if event.Data[0] == opcode {
+			events = append(events, event.Data)
+		}
+	}
+	return events
 }
 
 func TestNewWorld(t *testing.T) {
@@ -53,7 +85,7 @@ func TestAddBullet(t *testing.T) {
 func TestAddPlane(t *testing.T) {
 	w := getTestWorld()
 
-	w.addPlane(1, PlaneModel{}, w.gun)
+	w.addPlane(1, 0, PlaneModel{}, w.gun)
 
 	if len(w.planes) == 0 {
 		t.Fail()
@@ -66,12 +98,225 @@ func TestAddPlane(t *testing.T) {
 	}
 }
 
+func TestDetectHits(t *testing.T) {
+	w := getTestWorld()
+	w.addPlane(1, 0, PlaneModel{Life: 100}, w.gun)
+	w.addPlane(2, 0, PlaneModel{Life: 100}, w.gun)
+
+	target := w.planes[2]
+	target.location.X = 500
+
+	w.addBullet(&Bullet{source: 1, location: target.location, previous: target.location, damage: 40})
+	w.addBullet(&Bullet{source: 1, location: w.planes[1].location, previous: w.planes[1].location, damage: 40})
+
+	w.indexEntities()
+	w.detectHits(0.01)
+
+	if target.life != 60 {
+		t.Errorf("The target's life should be 60, not %d", target.life)
+	}
+
+	if w.planes[1].life != 100 {
+		t.Error("The shooter should not be hit by its own bullet")
+	}
+
+	if len(w.bullets) != 1 {
+		t.Errorf("Only one bullet should be left, not %d", len(w.bullets))
+	}
+}
+
+func TestGenerateSnapshots(t *testing.T) {
+	w := getTestWorld()
+	w.addPlane(1, 0, PlaneModel{}, w.gun)
+
+	w.addBullet(&Bullet{source: 1})
+	w.addBullet(&Bullet{source: 1})
+
+	snapshot := w.generateSnapshots()
+
+	const planes = 1 + 1 + 4 + 2 + PlaneSnapshotSize
+
+	if snapshot[0] != 0x3 || snapshot[1] != SnapshotVersion || binary.BigEndian.Uint16(snapshot[6:]) != 1 {
+		t.Errorf("Wrong header: %v", snapshot[0:8])
+	}
+
+	if binary.BigEndian.Uint16(snapshot[planes:]) != 2 {
+		t.Errorf("2 bullets should have spawned")
+	}
+
+	// The first bullet disappears, the second stays
+	w.despawnBullet(w.bullets[0])
+	// This one is gone before anybody saw it
+	w.addBullet(&Bullet{source: 1})
+	w.despawnBullet(w.bullets[2])
+
+	snapshot = w.generateSnapshots()
+
+	if binary.BigEndian.Uint16(snapshot[planes:]) != 0 {
+		t.Errorf("No bullet should have spawned")
+	}
+
+	if binary.BigEndian.Uint16(snapshot[planes+2:]) != 1 || binary.BigEndian.Uint16(snapshot[planes+4:]) != 0 {
+		t.Errorf("Only the bullet 0 should have despawned: %v", snapshot[planes:])
+	}
+
+	if len(snapshot) != planes+2+2+2+2+2 {
+		t.Errorf("The snapshot's length is %d", len(snapshot))
+	}
+}
+
+func TestRespawn(t *testing.T) {
+	w := getTestWorld()
+	w.addPlane(1, 0, PlaneModel{Life: 100}, w.gun)
+	plane := w.planes[1]
+
+	plane.takeDamage(100, PlaneDestroyed)
+	w.updateWorld(0.5)
+
+	states := eventsWithOpcode(w, 0x5)
+	if len(states) != 1 || states[0][2] != PlaneDestroyed {
+		t.Errorf("The destruction should be reported: %v", states)
+	}
+
+	if len(w.generateSnapshots()) != 1+1+4+2+2+2+2+2 {
+		t.Error("The plane should not be in the snapshot")
+	}
+
+	// Still waiting
+	w.updateWorld(0.5)
+
+	if !plane.isDead() || len(eventsWithOpcode(w, 0x5)) != 1 {
+		t.Error("The plane should not have respawned yet")
+	}
+
+	w.updateWorld(0.6)
+
+	if plane.isDead() || plane.life != 100 {
+		t.Error("The plane should have respawned")
+	}
+
+	states = eventsWithOpcode(w, 0x5)
+	if len(states) != 2 || states[1][2] != PlaneRespawned {
+		t.Errorf("The respawn should be reported: %v", states)
+	}
+}
+
+func TestReportWind(t *testing.T) {
+	w := getTestWorld()
+
+	w.updateWorld(0.5)
+	w.updateWorld(0.4)
+
+	if len(eventsWithOpcode(w, 0x6)) != 1 {
+		t.Error("The wind should be reported once")
+	}
+
+	w.updateWorld(0.7)
+
+	if len(eventsWithOpcode(w, 0x6)) != 2 {
+		t.Error("The wind should be reported every second")
+	}
+}
+
+func TestAdvance(t *testing.T) {
+	w := getTestWorld()
+
+	w.advance(0.025)
+
+	if w.tick != 2 {
+		t.Errorf("2 ticks should have run, not %d", w.tick)
+	}
+
+	// What was left is used by the next call
+	w.advance(0.005)
+
+	if w.tick != 3 {
+		t.Errorf("3 ticks should have run, not %d", w.tick)
+	}
+
+	// Too late: we don't catch up everything
+	w.advance(10)
+
+	if w.tick != 3+maxCatchUpSteps || w.accumulator >= w.timeStep {
+		t.Errorf("%d ticks have run, %f seconds are left", w.tick, w.accumulator)
+	}
+}
+
+func TestQueuedInputs(t *testing.T) {
+	w := getTestWorld()
+	w.addPlane(1, 0, PlaneModel{}, w.gun)
+
+	w.queueInput(PlayerInput{UID: 1, Data: []byte{0x3, 0, 0, 0, 0, 0x80}})
+
+	if w.planes[1].input.IsFiring {
+		t.Error("The input should wait for the next tick")
+	}
+
+	w.step()
+
+	if !w.planes[1].input.IsFiring {
+		t.Error("The input should be applied")
+	}
+}
+
+func TestDeterminism(t *testing.T) {
+
+	model := PlaneModel{
+		MaxThrust:     50000,
+		Mass:          4000,
+		MaxRotations:  mathutils.Vector3D{X: 0.3, Y: 0.3, Z: 1},
+		DragFactors:   mathutils.Vector3D{X: 0.05, Y: 0.005, Z: 0.05},
+		WingArea:      20,
+		LiftZero:      0.1,
+		LiftSlope:     5,
+		CriticalAngle: 0.3,
+		InducedDrag:   0.05,
+		DefaultSpeed:  150,
+		Life:          100,
+		CrashSpeed:    1000,
+		Guns:          []GunModel{dummyGunModel()},
+	}
+
+	run := func() *World {
+		w := getTestWorld()
+		w.scenario = dummyScenario()
+
+		for uid := uint8(1); uid <= 3; uid++ {
+			w.addPlane(uid, 0, model, w.gun)
+		}
+
+		for i := 0; i < 1000; i++ {
+			if i%50 == 0 {
+				uid := uint8(1 + (i/50)%3)
+				w.queueInput(PlayerInput{UID: uid, Data: []byte{0x3, byte(i), byte(i / 2), 12, 200, 0x80}})
+			}
+			w.step()
+		}
+		return w
+	}
+
+	w1 := run()
+	w2 := run()
+
+	for uid, p1 := range w1.planes {
+		p2 := w2.planes[uid]
+
+		if p1.location != p2.location || p1.speed != p2.speed || p1.orientation != p2.orientation || p1.life != p2.life {
+			t.Errorf("The plane %d is not in the same state in both runs", uid)
+		}
+	}
+
+	if len(w1.bullets) != len(w2.bullets) || w1.tick != w2.tick {
+		t.Error("Both worlds should be identical")
+	}
+}
+
 func BenchmarkXPlayers(b *testing.B) {
 	w := getTestWorld()
 	const X = 1
 
 	for i := 1; i <= X; i++ {
-		w.addPlane(uint8(i), PlaneModel{}, w.gun)
+		w.addPlane(uint8(i), 0, PlaneModel{}, w.gun)
 	}
 	deltaT := float64(time.Second / 100)
 
@@ -85,13 +330,13 @@ func BenchmarkXPlayers(b *testing.B) {
 }
 
 func BenchmarkBullet(b *testing.B) {
-	m := mathutils.NewMatrix3()
-	bullet := NewBullet(2, mathutils.Vector3D{X: 0, Y: 0, Z: 0}, &m, 600, 5)
+	bullet := NewBullet(2, mathutils.Vector3D{X: 0, Y: 0, Z: 0}, mathutils.Vector3D{X: 0, Y: 0, Z: 600}, 5, defaultBulletLifetime)
 
-	deltaT := float64(time.Second / 100)
+	deltaT := (time.Second / 100).Seconds()
+	atmosphere := Atmosphere{}.withDefaults()
 
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
-		bullet.Update(deltaT)
+		bullet.Update(deltaT, &atmosphere)
 	}
 }